@@ -0,0 +1,87 @@
+package startprompt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.design/x/clipboard"
+)
+
+/*
+剪贴板，复制、剪切和粘贴都通过 CommandLineOption.Clipboard 进行
+*/
+
+// Clipboard 剪贴板
+type Clipboard interface {
+	// Read 返回剪贴板中的文本
+	Read() (string, error)
+	// Write 将文本写入剪贴板
+	Write(text string) error
+}
+
+// MemClipboard 内存剪贴板，只在当前程序中有效
+type MemClipboard struct {
+	mutex sync.Mutex
+	text  string
+}
+
+func NewMemClipboard() *MemClipboard {
+	return &MemClipboard{}
+}
+
+func (mc *MemClipboard) Read() (string, error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return mc.text, nil
+}
+
+func (mc *MemClipboard) Write(text string) error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.text = text
+	return nil
+}
+
+// OSC52Clipboard 通过 OSC 52 转义序列写入终端的剪贴板，通过 SSH 连接时也可以使用
+//
+//	大部分终端出于安全考虑不允许读取剪贴板，所以 Read 返回最后一次写入的文本
+//	ref: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Operating-System-Commands
+type OSC52Clipboard struct {
+	MemClipboard
+	writer io.Writer
+}
+
+// NewOSC52Clipboard writer 一般是终端的输出，比如 os.Stdout
+func NewOSC52Clipboard(writer io.Writer) *OSC52Clipboard {
+	return &OSC52Clipboard{writer: writer}
+}
+
+func (oc *OSC52Clipboard) Write(text string) error {
+	_ = oc.MemClipboard.Write(text)
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	_, err := fmt.Fprintf(oc.writer, "\x1b]52;c;%s\a", encoded)
+	return err
+}
+
+// SystemClipboard 系统剪贴板，需要有图形界面（比如 X11 Wayland）
+type SystemClipboard struct{}
+
+// NewSystemClipboard 没有图形界面时返回错误
+func NewSystemClipboard() (*SystemClipboard, error) {
+	//     Init returns an error if the package is not ready for use.
+	if err := clipboard.Init(); err != nil {
+		return nil, err
+	}
+	return &SystemClipboard{}, nil
+}
+
+func (sc *SystemClipboard) Read() (string, error) {
+	return string(clipboard.Read(clipboard.FmtText)), nil
+}
+
+func (sc *SystemClipboard) Write(text string) error {
+	clipboard.Write(clipboard.FmtText, []byte(text))
+	return nil
+}
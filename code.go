@@ -1,6 +1,8 @@
 package startprompt
 
 import (
+	"context"
+
 	"github.com/yetsing/startprompt/token"
 )
 
@@ -11,10 +13,26 @@ import (
 type Completion struct {
 	// Display 展示给用户看的
 	Display string
-	// Suffix 加到用户输入后面的
+	// Suffix 加到用户输入后面的（ Text 和 StartPosition 都为空时使用）
 	Suffix string
+	// Text 替换的文本，会替换光标前 -StartPosition 个字符
+	// 比如输入 "AP" 时补全 "apple" ， Text 为 "apple" ， StartPosition 为 -2
+	Text string
+	// StartPosition 替换开始的位置，相对于光标，小于等于 0 ，大于 0 时按 0 处理
+	StartPosition int
 	// DisplayMeta 补全的元信息，比如补全是变量或者方法之类的
 	DisplayMeta string
+	// MatchPositions Display 中跟用户输入匹配的字符位置（字符索引），补全菜单会高亮这些字符，见 FuzzyMatch
+	MatchPositions []int
+}
+
+// replacement 返回替换开始的位置（相对于光标）和替换的文本
+func (c *Completion) replacement() (int, string) {
+	if c.Text == "" && c.StartPosition == 0 {
+		return 0, c.Suffix
+	}
+	//    不能替换光标后面的文本
+	return minInt(c.StartPosition, 0), c.Text
 }
 
 type CodeFactory func(document *Document) Code
@@ -35,6 +53,35 @@ type Code interface {
 	CompleteAfterInsertText() bool
 }
 
+// AsyncCompleter 可选接口， Code 实现后会在后台协程获取补全列表，获取补全时不会阻塞用户输入
+//
+//	补全菜单会随着补全的到来逐步填充，补全全部到来之前菜单中会显示加载动画
+type AsyncCompleter interface {
+	// GetCompletionsAsync 返回一个 channel ，将补全逐个发送到 channel 中，全部发送后关闭 channel
+	// 文本发生变化或者补全结束时 ctx 会被取消，此时应该尽快停止并关闭 channel
+	// ctx 取消后不会再有人接收补全，发送补全时需要同时等待 ctx.Done() ，避免协程一直阻塞
+	GetCompletionsAsync(ctx context.Context) <-chan *Completion
+}
+
+// Validator 可选接口， Code 实现后会在确定输入前检查输入是否有效
+type Validator interface {
+	// Validate 返回 nil 表示输入有效
+	// 返回错误时，输入不会被确定，光标移动到错误的位置，错误信息显示在输入下方
+	Validate() *ValidationError
+}
+
+// ValidationError 输入检查错误
+type ValidationError struct {
+	// Position 错误在输入中的位置（字符索引）
+	Position int
+	// Message 显示给用户的错误信息
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
 // _BaseCode Code 的默认实现
 type _BaseCode struct {
 	document *Document
@@ -47,8 +94,8 @@ func newBaseCode(document *Document) Code {
 func (c *_BaseCode) GetTokens() []token.Token {
 	return []token.Token{
 		{
-			token.Unspecific,
-			c.document.Text(),
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
 		},
 	}
 }
@@ -2,12 +2,17 @@ package startprompt
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"golang.org/x/term"
+
+	"github.com/yetsing/startprompt/terminalcode"
+	"github.com/yetsing/startprompt/token"
 )
 
 /*
@@ -54,9 +59,12 @@ const (
 	// PollEventInput 输入事件，用户按下键盘输入
 	// PollEventRedraw 重画事件，重画当前输入
 	// PollEventTimeout 超时事件，一段时间内没有其他事件触发
-	PollEventInput   PollEvent = "input"
-	PollEventRedraw  PollEvent = "redraw"
-	PollEventTimeout PollEvent = "timeout"
+	// PollEventReadError 读取输入出错，读取协程已经停止
+	PollEventInput     PollEvent = "input"
+	PollEventRedraw    PollEvent = "redraw"
+	PollEventTimeout   PollEvent = "timeout"
+	PollEventCancel    PollEvent = "cancel"
+	PollEventReadError PollEvent = "readerror"
 )
 
 // CommandLineOption 命令行选项
@@ -65,18 +73,45 @@ type CommandLineOption struct {
 	Schema Schema
 	// Handler 事件处理器
 	Handler EventHandler
+	// KeyBindings 按键绑定，绑定了命令的按键不再交给 Handler 处理
+	KeyBindings *KeyBindings
+	// ChordTimeout 多键组合（比如 Ctrl-X Ctrl-U）两次按键之间的最长等待时间，
+	// 超时后已按下的按键按照单个按键处理，默认 1 秒
+	ChordTimeout time.Duration
 	// History 输入历史存储
 	History History
 	// CodeFactory Code 类工厂方法
 	CodeFactory CodeFactory
 	// PromptFactory Prompt 类工厂方法
 	PromptFactory PromptFactory
+	// Toolbar 返回底部工具栏（状态栏）的内容，显示在输入和补全菜单下方，确定输入后不再显示
+	// 每次重绘（包括 RequestRedraw ）都会调用，可以用来显示实时状态
+	Toolbar func() []token.Token
+	// Clipboard 复制、剪切和粘贴使用的剪贴板
+	// CommandLine 默认是内存剪贴板， TCommandLine 默认是系统剪贴板（不可用时使用内存剪贴板）
+	// 需要通过 OSC 52 写入终端剪贴板时，设置为 NewOSC52Clipboard
+	Clipboard Clipboard
+	// CompletionDisplay 补全的展示方式，默认是单列菜单
+	CompletionDisplay CompletionDisplay
+	// CompletionMenuHeight 补全菜单的最大高度（多列菜单的行数），默认是 7
+	CompletionMenuHeight int
+	// CompletionQueryItems CompletionDisplayReadline 展示方式下，补全数量超过这个值时先询问是否列出，默认是 100
+	CompletionQueryItems int
 
 	// OnExit 用户停止时动作（Ctrl-D）
 	OnExit AbortAction
 	// OnAbort 用户中断时动作（Ctrl-C）
 	OnAbort AbortAction
 
+	// Input 输入流，默认是 os.Stdin （只对 CommandLine 生效）
+	// 可以是 pty 、网络连接或者内存管道，不是终端时不会开启 raw mode
+	Input io.Reader
+	// Output 输出流，默认是 os.Stdout （只对 CommandLine 生效）
+	Output io.Writer
+	// SizeFunc 返回终端窗口的宽度和高度（只对 CommandLine 生效）
+	// 默认读取 Input 或者 Output 的终端大小，都不是终端时为 80x24
+	SizeFunc func() (width int, height int)
+
 	// 自动缩进，如果开启，新行的缩进会与上一行保持一致
 	AutoIndent bool
 	// 开启 debug 日志
@@ -85,29 +120,43 @@ type CommandLineOption struct {
 
 // defaultCommandLineOption 默认命令行配置
 var defaultCommandLineOption = &CommandLineOption{
-	Schema:        defaultSchema,
-	Handler:       newBaseHandler(),
-	History:       NewMemHistory(),
-	CodeFactory:   newBaseCode,
-	PromptFactory: newBasePrompt,
-	OnAbort:       AbortActionRetry,
-	OnExit:        AbortActionReturnError,
-	AutoIndent:    false,
-	EnableDebug:   false,
+	Schema:               defaultSchema,
+	Handler:              newBaseHandler(),
+	History:              NewMemHistory(),
+	CodeFactory:          newBaseCode,
+	PromptFactory:        newBasePrompt,
+	ChordTimeout:         defaultChordTimeout,
+	CompletionDisplay:    CompletionDisplayColumn,
+	CompletionMenuHeight: defaultCompletionMenuHeight,
+	CompletionQueryItems: defaultCompletionQueryItems,
+	OnAbort:              AbortActionRetry,
+	OnExit:               AbortActionReturnError,
+	AutoIndent:           false,
+	EnableDebug:          false,
 }
 
 // copy 复制命令行配置，返回新的配置对象
 func (cp *CommandLineOption) copy() *CommandLineOption {
 	return &CommandLineOption{
-		Schema:        cp.Schema,
-		Handler:       cp.Handler,
-		History:       cp.History,
-		CodeFactory:   cp.CodeFactory,
-		PromptFactory: cp.PromptFactory,
-		OnAbort:       cp.OnAbort,
-		OnExit:        cp.OnExit,
-		AutoIndent:    cp.AutoIndent,
-		EnableDebug:   cp.EnableDebug,
+		Schema:               cp.Schema,
+		Handler:              cp.Handler,
+		KeyBindings:          cp.KeyBindings,
+		ChordTimeout:         cp.ChordTimeout,
+		History:              cp.History,
+		CodeFactory:          cp.CodeFactory,
+		PromptFactory:        cp.PromptFactory,
+		Toolbar:              cp.Toolbar,
+		Clipboard:            cp.Clipboard,
+		CompletionDisplay:    cp.CompletionDisplay,
+		CompletionMenuHeight: cp.CompletionMenuHeight,
+		CompletionQueryItems: cp.CompletionQueryItems,
+		OnAbort:              cp.OnAbort,
+		OnExit:               cp.OnExit,
+		Input:                cp.Input,
+		Output:               cp.Output,
+		SizeFunc:             cp.SizeFunc,
+		AutoIndent:           cp.AutoIndent,
+		EnableDebug:          cp.EnableDebug,
 	}
 }
 
@@ -119,6 +168,12 @@ func (cp *CommandLineOption) update(other *CommandLineOption) {
 	if other.Handler != nil {
 		cp.Handler = other.Handler
 	}
+	if other.KeyBindings != nil {
+		cp.KeyBindings = other.KeyBindings
+	}
+	if other.ChordTimeout > 0 {
+		cp.ChordTimeout = other.ChordTimeout
+	}
 	if other.History != nil {
 		cp.History = other.History
 	}
@@ -128,30 +183,56 @@ func (cp *CommandLineOption) update(other *CommandLineOption) {
 	if other.PromptFactory != nil {
 		cp.PromptFactory = other.PromptFactory
 	}
+	if other.Toolbar != nil {
+		cp.Toolbar = other.Toolbar
+	}
+	if other.Clipboard != nil {
+		cp.Clipboard = other.Clipboard
+	}
+	if other.CompletionDisplay != CompletionDisplayUnspecific {
+		cp.CompletionDisplay = other.CompletionDisplay
+	}
+	if other.CompletionMenuHeight > 0 {
+		cp.CompletionMenuHeight = other.CompletionMenuHeight
+	}
+	if other.CompletionQueryItems > 0 {
+		cp.CompletionQueryItems = other.CompletionQueryItems
+	}
 	if other.OnExit != AbortActionUnspecific {
 		cp.OnExit = other.OnExit
 	}
 	if other.OnAbort != AbortActionUnspecific {
 		cp.OnAbort = other.OnAbort
 	}
+	if other.Input != nil {
+		cp.Input = other.Input
+	}
+	if other.Output != nil {
+		cp.Output = other.Output
+	}
+	if other.SizeFunc != nil {
+		cp.SizeFunc = other.SizeFunc
+	}
 	cp.AutoIndent = other.AutoIndent
 	cp.EnableDebug = other.EnableDebug
 }
 
 type CommandLine struct {
-	// 标准输入和输出的缓冲读写
+	// 输入和输出的缓冲读写
 	reader *bufio.Reader
 	writer *bufio.Writer
+	//    输入流的终端文件描述符，不是终端时为 -1 ，此时不会开启 raw mode
+	inputFd int
 	//    配置选项
 	option *CommandLineOption
 	//    下面几个都用用于并发的情况
 	//    轮询超时时间
 	pollTimeout time.Duration
-	//    读取错误
+	//    读取错误，只在关闭 readChannel 前写入一次，从关闭的 readChannel 读取后才能访问
 	readError error
 	//    重画和读取 channel
 	redrawChannel chan rune
-	//    传输读取的 rune
+	//    传输读取的 rune ，读取出错时关闭
 	readChannel chan rune
 	//    是否正在读取用户输入
 	isReadingInput bool
@@ -163,29 +244,51 @@ type CommandLine struct {
 	//    命令行当前使用的 Line 和 Renderer 对象
 	line     *Line
 	renderer *Renderer
+	//    多次输入共用 kill ring
+	killRing *cKillRing
+	//    其他协程对当前输入的修改
+	invokeQueue *cInvokeQueue
 }
 
 // NewCommandLine 传入配置，新建命令行对象
 func NewCommandLine(option *CommandLineOption) (*CommandLine, error) {
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
-		return nil, fmt.Errorf("not in a terminal")
-	}
-	if !term.IsTerminal(int(os.Stdout.Fd())) {
-		return nil, fmt.Errorf("not in a terminal")
-	}
-
 	//     组合传入配置和默认配置
 	actualOption := defaultCommandLineOption.copy()
 	if option != nil {
 		actualOption.update(option)
 	}
 
-	reader := bufio.NewReader(os.Stdin)
-	writer := bufio.NewWriter(os.Stdout)
+	//    没有指定输入输出时，使用标准输入输出，此时要求必须是终端
+	if actualOption.Input == nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, fmt.Errorf("not in a terminal")
+		}
+		actualOption.Input = os.Stdin
+	}
+	if actualOption.Output == nil {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			return nil, fmt.Errorf("not in a terminal")
+		}
+		actualOption.Output = os.Stdout
+	}
+	inputFd := terminalFd(actualOption.Input)
+	if actualOption.SizeFunc == nil {
+		actualOption.SizeFunc = newTerminalSizeFunc(inputFd, terminalFd(actualOption.Output))
+	}
+	//    默认使用内存剪贴板，每个 CommandLine 各自一个，不会互相影响
+	if actualOption.Clipboard == nil {
+		actualOption.Clipboard = NewMemClipboard()
+	}
+
+	reader := bufio.NewReader(actualOption.Input)
+	writer := bufio.NewWriter(actualOption.Output)
 	c := &CommandLine{
-		reader: reader,
-		writer: writer,
-		option: actualOption,
+		reader:      reader,
+		writer:      writer,
+		inputFd:     inputFd,
+		option:      actualOption,
+		killRing:    newKillRing(),
+		invokeQueue: newInvokeQueue(),
 
 		redrawChannel: make(chan rune, 32),
 		readChannel:   make(chan rune, 1024),
@@ -211,22 +314,24 @@ func (c *CommandLine) setup() {
 			r, _, err := c.reader.ReadRune()
 			if err != nil {
 				//    发生错误时，停止读取（如果调用方忽略 ReadInput 返回的错误，是否应该继续读取？）
-				//    这个错误会由 ReadInput 判断并返回给调用方
+				//    这个错误会由 ReadInput 判断并返回给调用方，关闭 channel 保证之后读取 channel 时能看到这个错误
 				c.readError = err
-				c.readChannel <- 0
-				break
+				close(c.readChannel)
+				return
 			}
 			c.readChannel <- r
 		}
 	}()
 }
 
-// reset 重置 flag 和错误
+// reset 重置 flag
+//
+//	读取错误不会重置，因为发生错误后读取协程已经停止了，
+//	比如输入流是网络连接，连接断开后每次 ReadInput 都应该返回这个错误
 func (c *CommandLine) reset() {
 	c.exitFlag = false
 	c.abortFlag = false
 	c.acceptFlag = false
-	c.readError = nil
 }
 
 // Close 关闭命令行，现在这个方法啥也没做
@@ -235,28 +340,56 @@ func (c *CommandLine) Close() {
 }
 
 // RequestRedraw 请求重绘（ goroutine 安全）
+//
+//	不会阻塞，已经有重绘请求没有处理时，本次请求会合并到之前的请求中，
+//	没有在读取输入时，重绘会在下次 ReadInput 开始时执行
 func (c *CommandLine) RequestRedraw() {
-	if c.redrawChannel != nil {
-		c.redrawChannel <- 'x'
+	select {
+	case c.redrawChannel <- 'x':
+	default:
 	}
 }
 
+// Invoke 在事件循环中修改当前输入，修改后重绘（ goroutine 安全）
+//
+//	没有在读取输入时，修改会在下次 ReadInput 开始时执行，可以用来预先填充输入
+func (c *CommandLine) Invoke(fn func(line *Line)) {
+	c.invokeQueue.push(fn)
+	c.RequestRedraw()
+}
+
+// SetText 替换当前输入的文本并设置光标位置（ goroutine 安全），见 Invoke
+func (c *CommandLine) SetText(text string, cursor int) {
+	c.Invoke(func(line *Line) {
+		line.SetText(text, cursor)
+	})
+}
+
 // RunInExecutor 运行后台任务
 func (c *CommandLine) RunInExecutor(callback func()) {
 	go callback()
 }
 
 // pollEvent 轮询事件
-func (c *CommandLine) pollEvent() ([]rune, PollEvent) {
+func (c *CommandLine) pollEvent(ctx context.Context) ([]rune, PollEvent) {
 	select {
-	case r := <-c.readChannel:
+	case <-ctx.Done():
+		return nil, PollEventCancel
+	case r, ok := <-c.readChannel:
+		if !ok {
+			return nil, PollEventReadError
+		}
 		rbuf := []rune{r}
 		//    非阻塞的读取后续事件，优化粘贴大量文本的情况，快速处理，减少多次 render 导致的停顿感
 		runeReading := true
 		for runeReading {
 			select {
-			case r = <-c.readChannel:
-				rbuf = append(rbuf, r)
+			case r, ok = <-c.readChannel:
+				if ok {
+					rbuf = append(rbuf, r)
+				} else {
+					runeReading = false
+				}
 			default:
 				runeReading = false
 			}
@@ -277,66 +410,69 @@ func (c *CommandLine) pollEvent() ([]rune, PollEvent) {
 
 // ReadInput 读取用户输入
 func (c *CommandLine) ReadInput() (string, error) {
+	return c.ReadInputContext(context.Background())
+}
+
+// ReadInputContext 读取用户输入， ctx 取消（或者超时）时返回已经输入的文本和 ctx.Err()
+func (c *CommandLine) ReadInputContext(ctx context.Context) (string, error) {
 	if c.isReadingInput {
 		return "", fmt.Errorf("already reading input")
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	c.isReadingInput = true
-	c.redrawChannel = make(chan rune, 1024)
 	DebugLog("reading input")
 
-	renderer := newRenderer(c.option.Schema, c.option.PromptFactory)
-	c.renderer = renderer
-	line := newLine(
-		c.option.CodeFactory,
-		c.option.History,
-		c.option.AutoIndent,
-	)
-	c.line = line
-	handler := c.option.Handler
-	is := NewInputStream(handler, c)
-	renderer.render(line.GetRenderContext(), false, false)
-
-	resetFunc := func() {
-		is.Reset()
-		line.reset()
-		renderer.reset()
-		c.reset()
-	}
-	//    重置各个对象状态
-	resetFunc()
+	is, resetFunc := c.startInput()
+	c.line.invoke = c.Invoke
 
 	//    开启 terminal raw mode
 	//    这种模式下会拿到用户原始的输入，比如输入 Ctrl-c 时，不会中断当前程序，而是拿到 Ctrl-c 的表示
 	//    不会自动展示用户输入
 	//    更多说明解释参考：https://viewsourcecode.org/snaptoken/kilo/02.enteringRawMode.html
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		c.redrawChannel = nil
-		c.isReadingInput = false
-		return "", err
-	}
-	//    ReadInput 调用返回后，控制流程就到了用户，我们需要恢复终端的初始状态
-	defer func() {
-		err := term.Restore(int(os.Stdin.Fd()), oldState)
+	//    输入不是终端时（比如网络连接），由对端负责 raw mode
+	if c.inputFd != -1 {
+		oldState, err := term.MakeRaw(c.inputFd)
 		if err != nil {
-			fmt.Printf("term.Restore error: %v\r\n", err)
+			c.isReadingInput = false
+			return "", err
 		}
-	}()
+		//    ReadInput 调用返回后，控制流程就到了用户，我们需要恢复终端的初始状态
+		defer func() {
+			err := term.Restore(c.inputFd, oldState)
+			if err != nil {
+				fmt.Printf("term.Restore error: %v\r\n", err)
+			}
+		}()
+	}
+
+	//    开启 bracketed paste ，粘贴的文本作为一个粘贴事件处理，而不是一个个按键
+	c.Print(terminalcode.EnableBracketedPaste)
+	defer c.Print(terminalcode.DisableBracketedPaste)
 
-	var inputText string
 	for {
 		//    轮询事件
-		runes, pollEvent := c.pollEvent()
+		runes, pollEvent := c.pollEvent(ctx)
 		switch pollEvent {
+		case PollEventCancel:
+			//    跟放弃输入一样另起一行，返回已经输入的文本
+			//    正在进行的异步补全也一起取消，不会在读取结束后继续运行
+			c.line.cancelAsyncComplete()
+			c.renderer.render(c.line.GetRenderContext(), true, false)
+			c.isReadingInput = false
+			DebugLog("cancel input: <%s>, err: %v", c.line.text(), ctx.Err())
+			return c.line.text(), ctx.Err()
+		case PollEventReadError:
+			c.isReadingInput = false
+			return "", c.readError
 		case PollEventInput:
-			if c.readError != nil {
-				c.redrawChannel = nil
-				c.isReadingInput = false
-				return "", c.readError
-			}
 			DebugLog("read rune: [%d, ...] len=%d", runes[0], len(runes))
 			//    识别用户输入，触发事件
 			is.FeedRunes(runes)
+		case PollEventRedraw:
+			//    其他协程通过 Invoke 修改输入时也会请求重绘
+			c.runInvokes()
 		case PollEventTimeout:
 			//    读取用户输入超时
 			if !is.FeedTimeout() {
@@ -345,71 +481,128 @@ func (c *CommandLine) ReadInput() (string, error) {
 			}
 		}
 
-		//    处理特别的输入事件结果
-		if c.exitFlag {
-			DebugLog("handle exit flag, action: %s", c.option.OnExit)
-			//    一般是用户按了 Ctrl-D ，代表退出
-			switch c.option.OnExit {
-			case AbortActionReturnError:
-				renderer.render(line.GetRenderContext(), true, false)
-				c.redrawChannel = nil
-				c.isReadingInput = false
-				return "", ExitError
-			case AbortActionReturnNone:
-				renderer.render(line.GetRenderContext(), true, false)
-				c.redrawChannel = nil
-				c.isReadingInput = false
-				return "", nil
-			case AbortActionRetry:
-				resetFunc()
-			case AbortActionIgnore:
-
-			}
+		if done, inputText, err := c.handleFlags(resetFunc); done {
+			//    返回用户输入的文本内容
+			c.isReadingInput = false
+			DebugLog("return input: <%s>, err: %v", inputText, err)
+			return inputText, err
 		}
-		if c.abortFlag {
-			DebugLog("handle abort flag, action: %s", c.option.OnAbort)
-			//    一般是用户按了 Ctrl-C ，代表中断
-			switch c.option.OnAbort {
-			case AbortActionReturnError:
-				renderer.render(line.GetRenderContext(), true, false)
-				c.redrawChannel = nil
-				c.isReadingInput = false
-				return "", AbortError
-			case AbortActionReturnNone:
-				renderer.render(line.GetRenderContext(), true, false)
-				c.redrawChannel = nil
-				c.isReadingInput = false
-				return "", nil
-			case AbortActionRetry:
-				resetFunc()
-			case AbortActionIgnore:
 
-			}
-		}
-		if c.acceptFlag {
-			DebugLog("handle accept flag")
-			//    一般是用户按了 Enter ，代表完成本次输入
-			renderer.render(line.GetRenderContext(), false, true)
-			inputText = line.text()
-			break
+		//    画出用户输入
+		c.listCompletions()
+		c.renderer.render(c.line.GetRenderContext(), false, false)
+	}
+}
+
+// startInput 新建本次输入使用的 Line 和 Renderer 并画出提示符，返回输入流和重置函数
+func (c *CommandLine) startInput() (*InputStream, func()) {
+	renderer := newRenderer(
+		c.option.Output,
+		c.option.SizeFunc,
+		c.option.Schema,
+		c.option.PromptFactory,
+		c.option.Toolbar,
+	)
+	c.renderer = renderer
+	line := newLine(
+		c.option.CodeFactory,
+		c.option.History,
+		c.option.AutoIndent,
+	)
+	line.killRing = c.killRing
+	line.clipboard = c.option.Clipboard
+	line.completionDisplay = newCompletionDisplayOption(c.option)
+	c.line = line
+	handler := c.option.Handler
+	is := NewInputStream(handler, c)
+
+	resetFunc := func() {
+		is.Reset()
+		line.reset()
+		initHandlerLine(handler, line)
+		renderer.reset()
+		c.reset()
+	}
+	//    重置各个对象状态
+	resetFunc()
+	//    执行读取输入前添加的修改
+	c.runInvokes()
+	renderer.render(line.GetRenderContext(), false, false)
+	return is, resetFunc
+}
+
+// listCompletions 在输入下方列出补全（见 CompletionDisplayReadline ）
+func (c *CommandLine) listCompletions() {
+	if completions := c.line.takeCompletionListing(); completions != nil {
+		c.renderer.renderCompletions(c.line.GetRenderContext(), completions)
+	}
+}
+
+// runInvokes 执行其他协程对当前输入的修改，返回 false 表示没有修改
+func (c *CommandLine) runInvokes() bool {
+	if !c.invokeQueue.run(c.line) {
+		return false
+	}
+	//    跟按键事件一样，修改中确定了输入时设置完成标志
+	if c.line.IsAccept() {
+		c.SetAcceptFlag()
+	}
+	return true
+}
+
+// handleFlags 处理特别的输入事件结果（退出、中断、确定）
+// done 为 true 表示本次输入结束，此时返回用户输入的文本和错误
+func (c *CommandLine) handleFlags(resetFunc func()) (done bool, inputText string, err error) {
+	renderer := c.renderer
+	line := c.line
+	if c.exitFlag {
+		DebugLog("handle exit flag, action: %s", c.option.OnExit)
+		//    一般是用户按了 Ctrl-D ，代表退出
+		switch c.option.OnExit {
+		case AbortActionReturnError:
+			renderer.render(line.GetRenderContext(), true, false)
+			return true, "", ExitError
+		case AbortActionReturnNone:
+			renderer.render(line.GetRenderContext(), true, false)
+			return true, "", nil
+		case AbortActionRetry:
+			resetFunc()
+		case AbortActionIgnore:
+
 		}
+	}
+	if c.abortFlag {
+		DebugLog("handle abort flag, action: %s", c.option.OnAbort)
+		//    一般是用户按了 Ctrl-C ，代表中断
+		switch c.option.OnAbort {
+		case AbortActionReturnError:
+			renderer.render(line.GetRenderContext(), true, false)
+			return true, "", AbortError
+		case AbortActionReturnNone:
+			renderer.render(line.GetRenderContext(), true, false)
+			return true, "", nil
+		case AbortActionRetry:
+			resetFunc()
+		case AbortActionIgnore:
 
-		//    画出用户输入
-		renderer.render(line.GetRenderContext(), false, false)
+		}
+	}
+	if c.acceptFlag {
+		DebugLog("handle accept flag")
+		//    一般是用户按了 Enter ，代表完成本次输入
+		renderer.render(line.GetRenderContext(), false, true)
+		return true, line.text(), nil
 	}
-	//    返回用户输入的文本内容
-	c.redrawChannel = nil
-	c.isReadingInput = false
-	DebugLog("return input: <%s>, err: nil", inputText)
-	return inputText, nil
+	return false, "", nil
 }
 
 // ReadRune 读取 rune ，不能与 ReadInput 同时调用
 func (c *CommandLine) ReadRune() (rune, error) {
-	if c.readError != nil {
+	r, ok := <-c.readChannel
+	if !ok {
 		return 0, c.readError
 	}
-	return <-c.readChannel, nil
+	return r, nil
 }
 
 // GetLine 获取当前的 Line 对象，如果为 nil ，则 panic
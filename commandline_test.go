@@ -0,0 +1,207 @@
+package startprompt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCommandLine_ReadInputFromPipe(t *testing.T) {
+	reader, writer := io.Pipe()
+	var output bytes.Buffer
+	cli, err := NewCommandLine(&CommandLineOption{
+		Input:  reader,
+		Output: &output,
+		SizeFunc: func() (int, int) {
+			return 40, 10
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandLine error: %v", err)
+	}
+	defer cli.Close()
+
+	go func() {
+		_, _ = writer.Write([]byte("hello\r"))
+	}()
+	text, err := cli.ReadInput()
+	if err != nil {
+		t.Fatalf("ReadInput error: %v", err)
+	}
+	testStringEqual(t, "hello", text)
+	if !bytes.Contains(output.Bytes(), []byte("hello")) {
+		t.Errorf("output %q not contains input", output.String())
+	}
+
+	//    输入流关闭时返回读取错误
+	_ = writer.Close()
+	_, err = cli.ReadInput()
+	if err != io.EOF {
+		t.Errorf("want=%v, but got=%v", io.EOF, err)
+	}
+	//    读取协程已经停止，之后的读取都返回同样的错误
+	_, err = cli.ReadInput()
+	if err != io.EOF {
+		t.Errorf("want=%v, but got=%v", io.EOF, err)
+	}
+	_, err = cli.ReadRune()
+	if err != io.EOF {
+		t.Errorf("want=%v, but got=%v", io.EOF, err)
+	}
+}
+
+func TestCommandLine_ReadInputContext(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	var output bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	cli, err := NewCommandLine(&CommandLineOption{
+		Input:  reader,
+		Output: &output,
+		//    输入的文本处理完后再取消
+		CodeFactory: func(document *Document) Code {
+			if document.Text() == "partial" {
+				cancel()
+			}
+			return newBaseCode(document)
+		},
+		SizeFunc: func() (int, int) {
+			return 40, 10
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandLine error: %v", err)
+	}
+	defer cli.Close()
+
+	go func() {
+		_, _ = writer.Write([]byte("partial"))
+	}()
+	//    取消时返回已经输入的文本
+	text, err := cli.ReadInputContext(ctx)
+	if err != context.Canceled {
+		t.Fatalf("want=%v, but got=%v", context.Canceled, err)
+	}
+	testStringEqual(t, "partial", text)
+
+	//    取消后可以继续读取输入
+	go func() {
+		_, _ = writer.Write([]byte("next\r"))
+	}()
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer timeoutCancel()
+	text, err = cli.ReadInputContext(timeoutCtx)
+	if err != nil {
+		t.Fatalf("ReadInputContext error: %v", err)
+	}
+	testStringEqual(t, "next", text)
+}
+
+// _TestCancelAsyncCode 开始异步补全时调用 onStart ，补全永远不会到来
+type _TestCancelAsyncCode struct {
+	_BaseCode
+	onStart func(ctx context.Context)
+}
+
+func (c *_TestCancelAsyncCode) GetCompletionsAsync(ctx context.Context) <-chan *Completion {
+	c.onStart(ctx)
+	return make(chan *Completion)
+}
+
+func TestCommandLine_ReadInputContextCancelAsyncComplete(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var completeCtx context.Context
+	cli, err := NewCommandLine(&CommandLineOption{
+		Input:  reader,
+		Output: &bytes.Buffer{},
+		//    异步补全开始后取消读取
+		CodeFactory: func(document *Document) Code {
+			return &_TestCancelAsyncCode{_BaseCode{document: document}, func(c context.Context) {
+				completeCtx = c
+				cancel()
+			}}
+		},
+		SizeFunc: func() (int, int) {
+			return 40, 10
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandLine error: %v", err)
+	}
+	defer cli.Close()
+
+	go func() {
+		_, _ = writer.Write([]byte("a\t"))
+	}()
+	_, err = cli.ReadInputContext(ctx)
+	if err != context.Canceled {
+		t.Fatalf("want=%v, but got=%v", context.Canceled, err)
+	}
+	if completeCtx == nil || completeCtx.Err() == nil {
+		t.Errorf("async completion should be cancelled with the read")
+	}
+}
+
+func TestCommandLine_Invoke(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	var output bytes.Buffer
+	cli, err := NewCommandLine(&CommandLineOption{
+		Input:  reader,
+		Output: &output,
+		SizeFunc: func() (int, int) {
+			return 40, 10
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandLine error: %v", err)
+	}
+	defer cli.Close()
+
+	//    读取输入前添加的修改，在读取开始时执行，多次调用也不会阻塞
+	for i := 0; i < 100; i++ {
+		cli.Invoke(func(line *Line) {})
+	}
+	cli.SetText("git status", 3)
+	go func() {
+		//    在事件循环中确定输入
+		cli.Invoke(func(line *Line) {
+			line.InsertText([]rune("!"), true)
+			line.AcceptInput()
+		})
+	}()
+	text, err := cli.ReadInput()
+	if err != nil {
+		t.Fatalf("ReadInput error: %v", err)
+	}
+	testStringEqual(t, "git! status", text)
+}
+
+func TestCommandLine_DefaultClipboard(t *testing.T) {
+	newCli := func() *CommandLine {
+		cli, err := NewCommandLine(&CommandLineOption{
+			Input:  &bytes.Buffer{},
+			Output: &bytes.Buffer{},
+			SizeFunc: func() (int, int) {
+				return 40, 10
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewCommandLine error: %v", err)
+		}
+		return cli
+	}
+	cli1, cli2 := newCli(), newCli()
+	defer cli1.Close()
+	defer cli2.Close()
+	//    每个 CommandLine 使用各自的剪贴板
+	if err := cli1.option.Clipboard.Write("hello"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	text, _ := cli2.option.Clipboard.Read()
+	testStringEqual(t, "", text)
+}
@@ -0,0 +1,283 @@
+package startprompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+可组合的补全器，补全逻辑跟 Code 分开，可以在不同的程序中复用，比如
+
+	completer := startprompt.NewNestedCompleter(map[string]interface{}{
+		"git": map[string]interface{}{
+			"commit": []string{"--amend", "--message"},
+			"add":    startprompt.NewPathCompleter(),
+		},
+		"exit": nil,
+	}, false)
+	option := &startprompt.CommandLineOption{
+		CodeFactory: startprompt.WithCompleter(nil, completer),
+	}
+*/
+
+// Completer 补全器，根据文档返回补全列表
+type Completer interface {
+	// GetCompletions 返回光标处可选的补全列表
+	GetCompletions(document *Document) []*Completion
+}
+
+// CompleterFunc 将普通函数转换为 Completer
+type CompleterFunc func(document *Document) []*Completion
+
+func (f CompleterFunc) GetCompletions(document *Document) []*Completion {
+	return f(document)
+}
+
+// WordCompleter 从单词列表中补全光标前的单词（见 Document.GetWordBeforeCursor）
+type WordCompleter struct {
+	// Words 可选的单词，补全按照这里的顺序
+	Words []string
+	// Meta 单词的 DisplayMeta ，key 是 Words 中的单词
+	Meta map[string]string
+	// IgnoreCase 为 true 时忽略大小写
+	IgnoreCase bool
+	// MatchMiddle 为 true 时单词中间包含输入也算匹配，否则只匹配开头
+	MatchMiddle bool
+}
+
+func NewWordCompleter(words []string) *WordCompleter {
+	return &WordCompleter{Words: words}
+}
+
+func (wc *WordCompleter) GetCompletions(document *Document) []*Completion {
+	word := document.GetWordBeforeCursor()
+	pattern := word
+	if wc.IgnoreCase {
+		pattern = strings.ToLower(pattern)
+	}
+	patternLength := len([]rune(pattern))
+
+	var completions []*Completion
+	for _, candidate := range wc.Words {
+		text := candidate
+		if wc.IgnoreCase {
+			text = strings.ToLower(text)
+		}
+		index := strings.Index(text, pattern)
+		if index == -1 || (index > 0 && !wc.MatchMiddle) {
+			continue
+		}
+		//    转换为字符索引
+		start := len([]rune(text[:index]))
+		positions := make([]int, patternLength)
+		for i := range positions {
+			positions[i] = start + i
+		}
+		completions = append(completions, &Completion{
+			Display:        candidate,
+			Text:           candidate,
+			StartPosition:  -len([]rune(word)),
+			DisplayMeta:    wc.Meta[candidate],
+			MatchPositions: positions,
+		})
+	}
+	return completions
+}
+
+// NestedCompleter 根据命令树补全，比如 "git commit --amend"
+//
+//	第一个单词从 Options 的 key 中补全，输入第一个单词和空白后，
+//	剩下的文本交给这个单词对应的补全器补全，对应的补全器为 nil 时没有后续补全
+type NestedCompleter struct {
+	Options    map[string]Completer
+	IgnoreCase bool
+}
+
+// NewNestedCompleter 从嵌套的 map 创建 NestedCompleter ， map 的值可以是
+//
+//	nil                    没有后续补全
+//	Completer              使用这个补全器补全后续的文本
+//	map[string]interface{} 嵌套的命令树
+//	[]string               补全其中的单词
+//
+//	ignoreCase 同时用于嵌套的命令树和单词补全，直接传入的 Completer 不受影响
+func NewNestedCompleter(data map[string]interface{}, ignoreCase bool) *NestedCompleter {
+	options := make(map[string]Completer, len(data))
+	for key, value := range data {
+		switch v := value.(type) {
+		case nil:
+			options[key] = nil
+		case Completer:
+			options[key] = v
+		case map[string]interface{}:
+			options[key] = NewNestedCompleter(v, ignoreCase)
+		case []string:
+			options[key] = &WordCompleter{Words: v, IgnoreCase: ignoreCase}
+		default:
+			panic(fmt.Sprintf("unsupported nested completer value %T for %q", value, key))
+		}
+	}
+	return &NestedCompleter{Options: options, IgnoreCase: ignoreCase}
+}
+
+func (nc *NestedCompleter) GetCompletions(document *Document) []*Completion {
+	text := strings.TrimLeftFunc(document.TextBeforeCursor(), unicode.IsSpace)
+	index := strings.IndexFunc(text, unicode.IsSpace)
+	if index == -1 {
+		//    还在输入第一个单词
+		keys := make([]string, 0, len(nc.Options))
+		for key := range nc.Options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		wc := &WordCompleter{Words: keys, IgnoreCase: nc.IgnoreCase}
+		return wc.GetCompletions(NewDocument(text, len([]rune(text))))
+	}
+
+	first := text[:index]
+	completer, ok := nc.Options[first]
+	if !ok && nc.IgnoreCase {
+		for key, value := range nc.Options {
+			if strings.EqualFold(key, first) {
+				completer, ok = value, true
+				break
+			}
+		}
+	}
+	if !ok || completer == nil {
+		return nil
+	}
+	remaining := strings.TrimLeftFunc(text[index:], unicode.IsSpace)
+	return completer.GetCompletions(NewDocument(remaining, len([]rune(remaining))))
+}
+
+// MergedCompleter 合并多个补全器的补全，按照补全器的顺序排列
+type MergedCompleter struct {
+	Completers []Completer
+}
+
+func NewMergedCompleter(completers ...Completer) *MergedCompleter {
+	return &MergedCompleter{Completers: completers}
+}
+
+func (mc *MergedCompleter) GetCompletions(document *Document) []*Completion {
+	var completions []*Completion
+	for _, completer := range mc.Completers {
+		completions = append(completions, completer.GetCompletions(document)...)
+	}
+	return completions
+}
+
+// FuzzyCompleter 将补全器的补全改为模糊匹配（见 FuzzyMatch）
+//
+//	去掉光标前的单词后从 Completer 获取全部补全，再用单词模糊匹配补全的 Display ，
+//	按照得分从高到低排序
+type FuzzyCompleter struct {
+	Completer Completer
+}
+
+func NewFuzzyCompleter(completer Completer) *FuzzyCompleter {
+	return &FuzzyCompleter{Completer: completer}
+}
+
+func (fc *FuzzyCompleter) GetCompletions(document *Document) []*Completion {
+	word := document.GetWordBeforeCursor()
+	wordLength := len([]rune(word))
+	before := []rune(document.TextBeforeCursor())
+	before = before[:len(before)-wordLength]
+	inner := NewDocument(string(before)+document.TextAfterCursor(), len(before))
+
+	type scoredCompletion struct {
+		completion *Completion
+		score      int
+	}
+	var results []scoredCompletion
+	for _, completion := range fc.Completer.GetCompletions(inner) {
+		score, positions, matched := FuzzyMatch(word, completion.Display)
+		if !matched {
+			continue
+		}
+		start, text := completion.replacement()
+		results = append(results, scoredCompletion{
+			completion: &Completion{
+				Display:        completion.Display,
+				Text:           text,
+				StartPosition:  start - wordLength,
+				DisplayMeta:    completion.DisplayMeta,
+				MatchPositions: positions,
+			},
+			score: score,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	completions := make([]*Completion, len(results))
+	for i, result := range results {
+		completions[i] = result.completion
+	}
+	return completions
+}
+
+// WithCompleter 返回的 CodeFactory 使用 completer 补全，其他的跟 factory 创建的 Code 一样
+//
+//	factory 为 nil 时使用默认的 Code ，
+//	factory 创建的 Code 实现的 AsyncCompleter 会被忽略， Validator 仍然有效
+func WithCompleter(factory CodeFactory, completer Completer) CodeFactory {
+	if factory == nil {
+		factory = newBaseCode
+	}
+	return func(document *Document) Code {
+		code := &_CompleterCode{
+			Code:      factory(document),
+			document:  document,
+			completer: completer,
+		}
+		//    只有 factory 创建的 Code 实现了 Validator 时才实现 Validator
+		if validator, ok := code.Code.(Validator); ok {
+			return &_ValidatorCompleterCode{_CompleterCode: code, validator: validator}
+		}
+		return code
+	}
+}
+
+// _CompleterCode 使用 Completer 补全的 Code
+type _CompleterCode struct {
+	Code
+	document  *Document
+	completer Completer
+}
+
+// Complete 只有一个补全并且补全是输入的延续时，返回需要添加的文本
+func (c *_CompleterCode) Complete() string {
+	completions := c.GetCompletions()
+	if len(completions) != 1 {
+		return ""
+	}
+	start, text := completions[0].replacement()
+	before := []rune(c.document.TextBeforeCursor())
+	if -start > len(before) {
+		return ""
+	}
+	replaced := string(before[len(before)+start:])
+	if !strings.HasPrefix(text, replaced) {
+		return ""
+	}
+	return text[len(replaced):]
+}
+
+func (c *_CompleterCode) GetCompletions() []*Completion {
+	return c.completer.GetCompletions(c.document)
+}
+
+// _ValidatorCompleterCode 内部 Code 实现了 Validator 的 _CompleterCode
+type _ValidatorCompleterCode struct {
+	*_CompleterCode
+	validator Validator
+}
+
+func (c *_ValidatorCompleterCode) Validate() *ValidationError {
+	return c.validator.Validate()
+}
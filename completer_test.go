@@ -0,0 +1,113 @@
+package startprompt
+
+import (
+	"fmt"
+	"testing"
+)
+
+// completeText 返回光标在 text 末尾时的补全，格式为 Display|Text|StartPosition|DisplayMeta
+func completeText(completer Completer, text string) string {
+	var got []string
+	for _, completion := range completer.GetCompletions(NewDocument(text, len([]rune(text)))) {
+		start, replacement := completion.replacement()
+		got = append(got, fmt.Sprintf("%s|%s|%d|%s", completion.Display, replacement, start, completion.DisplayMeta))
+	}
+	return fmt.Sprint(got)
+}
+
+func TestWordCompleter(t *testing.T) {
+	wc := NewWordCompleter([]string{"apple", "Apricot", "banana", "pineapple"})
+	testStringEqual(t, "[apple|apple|-2|]", completeText(wc, "eat ap"))
+	testStringEqual(t, "[apple|apple|0| Apricot|Apricot|0| banana|banana|0| pineapple|pineapple|0|]", completeText(wc, "eat "))
+	wc.IgnoreCase = true
+	testStringEqual(t, "[apple|apple|-2| Apricot|Apricot|-2|]", completeText(wc, "eat ap"))
+	wc.MatchMiddle = true
+	testStringEqual(t, "[apple|apple|-2| Apricot|Apricot|-2| pineapple|pineapple|-2|]", completeText(wc, "eat ap"))
+	completions := wc.GetCompletions(NewDocument("pl", 2))
+	testStringEqual(t, "[2 3]", fmt.Sprint(completions[0].MatchPositions))
+}
+
+func TestNestedCompleter(t *testing.T) {
+	nc := NewNestedCompleter(map[string]interface{}{
+		"git": map[string]interface{}{
+			"commit": []string{"--amend", "--message"},
+			"checkout": CompleterFunc(func(document *Document) []*Completion {
+				return []*Completion{{Display: "main", Text: "main", StartPosition: -len(document.Text())}}
+			}),
+		},
+		"exit": nil,
+	}, false)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "[exit|exit|0| git|git|0|]"},
+		{"g", "[git|git|-1|]"},
+		{"git ", "[checkout|checkout|0| commit|commit|0|]"},
+		{"  git  c", "[checkout|checkout|-1| commit|commit|-1|]"},
+		{"git commit --a", "[--amend|--amend|-3|]"},
+		{"git checkout ma", "[main|main|-2|]"},
+		{"exit ", "[]"},
+		{"unknown ", "[]"},
+	}
+	for _, tt := range tests {
+		testStringEqual(t, tt.want, completeText(nc, tt.text))
+	}
+
+	//    忽略大小写对嵌套的命令树同样有效
+	nc = NewNestedCompleter(map[string]interface{}{
+		"git": map[string]interface{}{
+			"commit": []string{"--amend"},
+		},
+	}, true)
+	testStringEqual(t, "[commit|commit|-3|]", completeText(nc, "GIT COM"))
+	testStringEqual(t, "[--amend|--amend|-3|]", completeText(nc, "Git Commit --A"))
+}
+
+func TestMergedCompleter(t *testing.T) {
+	mc := NewMergedCompleter(NewWordCompleter([]string{"cat", "cd"}), NewWordCompleter([]string{"cp", "dog"}))
+	testStringEqual(t, "[cat|cat|-1| cd|cd|-1| cp|cp|-1|]", completeText(mc, "c"))
+}
+
+func TestFuzzyCompleter(t *testing.T) {
+	fc := NewFuzzyCompleter(NewWordCompleter([]string{"decor", "chocolate", "cow", "copy", "dog"}))
+	testStringEqual(t, "[cow|cow|-2| copy|copy|-2| chocolate|chocolate|-2| decor|decor|-2|]", completeText(fc, "eat co"))
+	completions := fc.GetCompletions(NewDocument("dr", 2))
+	testStringEqual(t, "[0 4]", fmt.Sprint(completions[0].MatchPositions))
+}
+
+func TestWithCompleter(t *testing.T) {
+	completer := NewWordCompleter([]string{"apple", "apricot", "banana"})
+	line := newLine(WithCompleter(nil, completer), NewMemHistory(), false)
+	//    只有一个补全时直接补全
+	line.InsertText([]rune("eat b"), true)
+	testBoolEqual(t, true, line.Complete())
+	testStringEqual(t, "eat banana", line.text())
+
+	line.InsertText([]rune(" ap"), true)
+	testBoolEqual(t, false, line.Complete())
+	line.CompleteNext(1)
+	testStringEqual(t, "eat banana apple", line.text())
+	line.CompleteNext(1)
+	testStringEqual(t, "eat banana apricot", line.text())
+}
+
+func TestWithCompleter_Validator(t *testing.T) {
+	completer := NewWordCompleter([]string{"apple"})
+	//    只有 factory 创建的 Code 实现了 Validator 时才是 Validator
+	code := WithCompleter(nil, completer)(NewDocument("a1", 2))
+	if _, ok := code.(Validator); ok {
+		t.Errorf("code should not implement Validator")
+	}
+	code = WithCompleter(func(document *Document) Code {
+		return &_TestValidateCode{_BaseCode{document: document}}
+	}, completer)(NewDocument("1 a", 3))
+	validator, ok := code.(Validator)
+	if !ok {
+		t.Fatalf("code should implement Validator")
+	}
+	if validator.Validate() == nil {
+		t.Errorf("expected validation error")
+	}
+	testIntEqual(t, 1, len(code.GetCompletions()))
+}
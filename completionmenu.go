@@ -1,10 +1,55 @@
 package startprompt
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/mattn/go-runewidth"
 	"github.com/yetsing/startprompt/token"
 )
 
+// CompletionDisplay 补全的展示方式
+type CompletionDisplay string
+
+//goland:noinspection GoUnusedConst
+const (
+	// CompletionDisplayUnspecific 空值
+	CompletionDisplayUnspecific CompletionDisplay = ""
+	// CompletionDisplayColumn 单列菜单，上下键切换补全（默认）
+	CompletionDisplayColumn CompletionDisplay = "column"
+	// CompletionDisplayMultiColumn 多列菜单，补全从上到下、从左到右排列，左右键在列之间切换
+	CompletionDisplayMultiColumn CompletionDisplay = "multi-column"
+	// CompletionDisplayReadline 跟 readline 一样，补全有共同前缀时补全前缀，否则在输入下方列出全部补全，
+	// 补全数量超过 CommandLineOption.CompletionQueryItems 时先询问 "Display all 300 possibilities? (y/n)"
+	CompletionDisplayReadline CompletionDisplay = "readline"
+)
+
+// defaultCompletionMenuHeight 补全菜单默认的最大高度
+const defaultCompletionMenuHeight = 7
+
+// defaultCompletionQueryItems 补全数量超过它时先询问是否列出，跟 readline 的 completion-query-items 一样
+const defaultCompletionQueryItems = 100
+
+// cCompletionDisplayOption 补全的展示配置，来自 CommandLineOption
+type cCompletionDisplayOption struct {
+	display    CompletionDisplay
+	menuHeight int
+	queryItems int
+}
+
+func newCompletionDisplayOption(option *CommandLineOption) cCompletionDisplayOption {
+	return cCompletionDisplayOption{
+		display:    option.CompletionDisplay,
+		menuHeight: option.CompletionMenuHeight,
+		queryItems: option.CompletionQueryItems,
+	}
+}
+
+// rows 返回菜单的行数，多列菜单每列有这么多个补全
+func (o cCompletionDisplayOption) rows(count int) int {
+	return maxInt(1, minInt(o.menuHeight, count))
+}
+
 // cCompletionMenuInfo 补全菜单信息（用于判断鼠标点击）
 type cCompletionMenuInfo struct {
 	//    显示区域
@@ -12,6 +57,9 @@ type cCompletionMenuInfo struct {
 	//    补全项的开始和结束索引
 	sliceFrom int
 	sliceTo   int
+	//    多列菜单的行数和列宽，单列菜单列宽为 0
+	rows        int
+	columnWidth int
 }
 
 // getCompleteIndex 返回坐标位置在第几个补全项上
@@ -19,8 +67,15 @@ func (c *cCompletionMenuInfo) getCompleteIndex(coordinate Coordinate) int {
 	if !c.area.RectContains(coordinate) {
 		return -1
 	}
-	startY := c.area.getStart().Y
-	return c.sliceFrom + (coordinate.Y - startY)
+	start := c.area.getStart()
+	offset := coordinate.Y - start.Y
+	if c.columnWidth > 0 {
+		offset += (coordinate.X - start.X) / c.columnWidth * c.rows
+	}
+	if c.sliceFrom+offset >= c.sliceTo {
+		return -1
+	}
+	return c.sliceFrom + offset
 }
 
 // cCompletionMenu 辅助补全菜单的渲染
@@ -28,18 +83,18 @@ type cCompletionMenu struct {
 	screen        *Screen
 	completeState *cCompletionState
 	info          *cCompletionMenuInfo
-	maxHeight     int
+	option        cCompletionDisplayOption
 
 	progressButtonToken token.Token
 	progressBarToken    token.Token
 }
 
-func newCompletionMenu(screen *Screen, completeState *cCompletionState, maxHeight int) *cCompletionMenu {
+func newCompletionMenu(screen *Screen, completeState *cCompletionState, option cCompletionDisplayOption) *cCompletionMenu {
 	return &cCompletionMenu{
 		screen:        screen,
 		completeState: completeState,
 		info:          &cCompletionMenuInfo{},
-		maxHeight:     maxHeight,
+		option:        option,
 
 		progressButtonToken: token.NewToken(token.CompletionMenuProgressButton, " "),
 		progressBarToken:    token.NewToken(token.CompletionMenuProgressBar, " "),
@@ -112,21 +167,37 @@ func (c *cCompletionMenu) getMenuMetaWidth() int {
 
 // write 将菜单写入 screen 里面
 func (c *cCompletionMenu) write() {
+	switch c.option.display {
+	case CompletionDisplayMultiColumn:
+		c.writeColumns()
+	case CompletionDisplayReadline:
+		//    补全会列在输入下方，这里只显示异步补全的加载动画
+		if c.completeState.loading {
+			c.screen.writeTokensBelow([]token.Token{c.getLoadingToken()})
+		}
+	default:
+		c.writeColumn()
+	}
+}
+
+// writeColumn 写入单列菜单
+func (c *cCompletionMenu) writeColumn() {
 	completions := c.completeState.currentCompletions
 	index := c.completeState.completeIndex
+	maxHeight := c.option.menuHeight
 
 	//    决定从哪个补全项开始展示
 	sliceFrom := 0
 	//    补全项多于最大高度并且当前选择项在下半部分位置，需要向上移动补全菜单
 	//    尽可能地让选中的补全项位于菜单中上部分
-	if len(completions) > c.maxHeight && index != -1 && index > c.maxHeight/2 {
+	if len(completions) > maxHeight && index != -1 && index > maxHeight/2 {
 		sliceFrom = minInt(
-			index-c.maxHeight/2,          // 将选择项移到中间位置
-			len(completions)-c.maxHeight, // 最后一个补全在最底部
+			index-maxHeight/2,          // 将选择项移到中间位置
+			len(completions)-maxHeight, // 最后一个补全在最底部
 		)
 	}
 
-	sliceTo := minInt(sliceFrom+c.maxHeight, len(completions))
+	sliceTo := minInt(sliceFrom+maxHeight, len(completions))
 
 	//    计算补全菜单的宽度
 	menuWidth := c.getMenuWidth()
@@ -138,10 +209,8 @@ func (c *cCompletionMenu) write() {
 	//    写入补全到 screen
 	for i, completion := range completions[sliceFrom:sliceTo] {
 		//    i+sliceFrom == index 判断补全项是否已选中
-		tks := []token.Token{
-			token.NewToken(token.Unspecific, " "),
-			c.getMenuItemToken(completion, i+sliceFrom == index, menuWidth),
-		}
+		tks := []token.Token{token.NewToken(token.Unspecific, " ")}
+		tks = append(tks, c.getMenuItemTokens(completion, i+sliceFrom == index, menuWidth)...)
 		if showMeta {
 			tks = append(
 				tks,
@@ -156,6 +225,13 @@ func (c *cCompletionMenu) write() {
 		tks = append(tks, token.NewToken(token.Unspecific, " "))
 		c.screen.WriteTokensAtPos(coordinate.X, coordinate.Y+i, tks)
 	}
+	//    等待异步补全时，在最后一行显示加载动画
+	if c.completeState.loading {
+		c.screen.WriteTokensAtPos(coordinate.X, coordinate.Y+sliceTo-sliceFrom, []token.Token{
+			token.NewToken(token.Unspecific, " "),
+			c.getLoadingToken(),
+		})
+	}
 	lastCoordinate := c.screen.getLastCoordinate()
 	//    lastCoordinate 是最后一个字符的坐标，同样要包含在 area 里面
 	//    而 area 是左闭右开区间，所以需要加 1
@@ -165,14 +241,100 @@ func (c *cCompletionMenu) write() {
 	c.info.sliceTo = sliceTo
 }
 
-func (c *cCompletionMenu) getMenuItemToken(completion *Completion, isCurrentCompletion bool, width int) token.Token {
-	var ttype token.TokenType
+// writeColumns 写入多列菜单，补全从上到下、从左到右排列，列数超过窗口宽度时左右滚动
+func (c *cCompletionMenu) writeColumns() {
+	completions := c.completeState.currentCompletions
+	index := c.completeState.completeIndex
+	rows := c.option.rows(len(completions))
+	columnCount := (len(completions) + rows - 1) / rows
+
+	//    补全项前后各有 1 个空格
+	itemWidth := c.getMenuWidth()
+	columnWidth := itemWidth + 2
+	visibleColumns := maxInt(1, minInt(columnCount, (c.screen.Width()-1)/columnWidth))
+	//    决定从哪一列开始展示，保证选中的补全在展示的列中
+	columnFrom := 0
+	if index != -1 && index/rows >= visibleColumns {
+		columnFrom = index/rows - visibleColumns + 1
+	}
+	sliceFrom := columnFrom * rows
+	sliceTo := minInt(sliceFrom+visibleColumns*rows, len(completions))
+
+	menuWidth := visibleColumns * columnWidth
+	coordinate := c.getDrawCoordinate(menuWidth)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < visibleColumns; column++ {
+			x := coordinate.X + column*columnWidth
+			i := sliceFrom + column*rows + row
+			if i >= sliceTo {
+				//    最后一列不满时补上空白，保持菜单是一个矩形
+				c.screen.WriteTokensAtPos(x, coordinate.Y+row, []token.Token{
+					token.NewToken(token.CompletionMenuCompletion, repeatByte(' ', columnWidth)),
+				})
+				continue
+			}
+			c.screen.WriteTokensAtPos(x, coordinate.Y+row, c.getMenuItemTokens(completions[i], i == index, itemWidth+1))
+		}
+	}
+	y := coordinate.Y + rows
+	//    在菜单下方显示选中补全的元信息
+	if completion := c.completeState.currentCompletion(); completion != nil && completion.DisplayMeta != "" {
+		c.screen.WriteTokensAtPos(coordinate.X, y, []token.Token{
+			c.getMenuItemMetaToken(completion, true, menuWidth-1),
+		})
+		y++
+	}
+	if c.completeState.loading {
+		c.screen.WriteTokensAtPos(coordinate.X, y, []token.Token{c.getLoadingToken()})
+	}
+	c.info.area = area{coordinate, Coordinate{X: coordinate.X + menuWidth, Y: coordinate.Y + rows}}
+	c.info.sliceFrom = sliceFrom
+	c.info.sliceTo = sliceTo
+	c.info.rows = rows
+	c.info.columnWidth = columnWidth
+}
+
+// getLoadingToken 返回等待异步补全时的加载动画
+func (c *cCompletionMenu) getLoadingToken() token.Token {
+	return token.NewToken(token.CompletionMenuLoading, " "+spinnerFrame(time.Now())+" ")
+}
+
+// getMenuItemTokens 返回补全项的 token ，匹配的字符使用 CompletionMenuMatch 样式
+func (c *cCompletionMenu) getMenuItemTokens(completion *Completion, isCurrentCompletion bool, width int) []token.Token {
+	var ttype, matchType token.TokenType
 	if isCurrentCompletion {
 		ttype = token.CompletionMenuCompletionCurrent
+		matchType = token.CompletionMenuMatchCurrent
 	} else {
 		ttype = token.CompletionMenuCompletion
+		matchType = token.CompletionMenuMatch
 	}
-	return token.NewToken(ttype, " "+ljustWidth(completion.Display, width))
+	text := " " + ljustWidth(completion.Display, width)
+	if len(completion.MatchPositions) == 0 {
+		return []token.Token{token.NewToken(ttype, text)}
+	}
+
+	matched := make(map[int]bool, len(completion.MatchPositions))
+	for _, position := range completion.MatchPositions {
+		//    前面加了一个空格
+		matched[position+1] = true
+	}
+	//    相邻的同类字符合并成一个 token
+	var tks []token.Token
+	runes := []rune(text)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && matched[i] == matched[start] {
+			continue
+		}
+		if matched[start] {
+			tks = append(tks, token.NewToken(matchType, string(runes[start:i])))
+		} else {
+			tks = append(tks, token.NewToken(ttype, string(runes[start:i])))
+		}
+		start = i
+	}
+	return tks
 }
 
 func (c *cCompletionMenu) getMenuItemMetaToken(completion *Completion, isCurrentCompletion bool, width int) token.Token {
@@ -185,6 +347,48 @@ func (c *cCompletionMenu) getMenuItemMetaToken(completion *Completion, isCurrent
 	return token.NewToken(ttype, " "+ljustWidth(completion.DisplayMeta, width))
 }
 
+// spinnerFrames 加载动画的帧
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// spinnerFrame 返回 t 时刻加载动画的帧
+func spinnerFrame(t time.Time) string {
+	index := t.UnixMilli() / asyncCompleteSpinnerInterval.Milliseconds() % int64(len(spinnerFrames))
+	return string(spinnerFrames[index])
+}
+
+// getCompletionQueryTokens 返回询问是否列出全部补全的 token
+func getCompletionQueryTokens(count int) []token.Token {
+	return []token.Token{
+		token.NewToken(token.CompletionQuery, fmt.Sprintf("Display all %d possibilities? (y/n)", count)),
+	}
+}
+
+// answerCompletionQuery 用按键回答是否列出全部补全（见 linemode.CompletionQuery）
+// 返回 false 表示回答后按键还需要按照平常的方式处理
+//
+//	跟 readline 一样， y Y 和空格表示列出， n N Backspace Ctrl-G Ctrl-C 和 Esc 表示不列出，其他按键忽略
+//	Ctrl-C 不列出之后还会继续中断输入
+func answerCompletionQuery(line *Line, eventType EventType, data []rune) bool {
+	switch eventType {
+	case EventTypeInsertChar:
+		if len(data) == 0 {
+			break
+		}
+		switch data[0] {
+		case 'y', 'Y', ' ':
+			line.AnswerCompletionQuery(true)
+		case 'n', 'N':
+			line.AnswerCompletionQuery(false)
+		}
+	case EventTypeBackspace, EventTypeCtrlH, EventTypeCtrlG, EventTypeEscape:
+		line.AnswerCompletionQuery(false)
+	case EventTypeCtrlC:
+		line.AnswerCompletionQuery(false)
+		return false
+	}
+	return true
+}
+
 // getInfo 获取补全信息
 func (c *cCompletionMenu) getInfo() *cCompletionMenuInfo {
 	return c.info
@@ -0,0 +1,49 @@
+package startprompt
+
+import (
+	"testing"
+)
+
+func TestCompletionMenuInfo_GetCompleteIndex(t *testing.T) {
+	//    多列菜单，每列 2 行，列宽 4 ，从第 2 列开始展示
+	info := &cCompletionMenuInfo{
+		area:        area{Coordinate{2, 1}, Coordinate{14, 3}},
+		sliceFrom:   2,
+		sliceTo:     7,
+		rows:        2,
+		columnWidth: 4,
+	}
+	tests := []struct {
+		coordinate Coordinate
+		want       int
+	}{
+		{Coordinate{2, 1}, 2},
+		{Coordinate{5, 2}, 3},
+		{Coordinate{6, 1}, 4},
+		{Coordinate{13, 1}, 6},
+		//    最后一列的空白
+		{Coordinate{13, 2}, -1},
+		//    菜单外面
+		{Coordinate{1, 1}, -1},
+		{Coordinate{2, 3}, -1},
+	}
+	for _, tt := range tests {
+		testIntEqual(t, tt.want, info.getCompleteIndex(tt.coordinate))
+	}
+}
+
+func TestRunHeadless_CompletionMenuHeight(t *testing.T) {
+	option := &CommandLineOption{
+		CodeFactory:          WithCompleter(nil, NewWordCompleter([]string{"a1", "a2", "a3", "a4", "a5"})),
+		CompletionMenuHeight: 2,
+		SizeFunc: func() (int, int) {
+			return 20, 6
+		},
+	}
+	result, err := RunHeadless(option, "a<tab><arrow_down><arrow_down>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "a3", result.Text)
+	testStringEqual(t, "> a3\n    a2\n    a3", result.Screen())
+}
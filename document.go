@@ -364,6 +364,59 @@ func (d *Document) findNextWordEnding(includeCurrentPosition bool) int {
 	}
 }
 
+// findMatchingBracket 光标处是括号时，返回匹配的另一个括号的位置，找不到返回 -1
+func (d *Document) findMatchingBracket() int {
+	brackets := []struct {
+		left  string
+		right string
+	}{
+		{"(", ")"},
+		{"[", "]"},
+		{"{", "}"},
+		{"<", ">"},
+	}
+	document := d
+	stack := 1
+	for _, bracket := range brackets {
+		if document.CurrentChar() == bracket.left {
+			// 寻找匹配的右括号
+			text := document.TextAfterCursor()
+			step := 0
+			for _, r := range stringStartAt(text, 1) {
+				if string(r) == bracket.left {
+					stack++
+				} else if string(r) == bracket.right {
+					stack--
+				}
+				if stack == 0 {
+					// 是从 1 开始遍历的，所以这里需要加 1
+					return d.cursorPosition + step + 1
+				}
+				step++
+			}
+		} else if document.CurrentChar() == bracket.right {
+			// 寻找匹配的左括号
+			text := document.TextBeforeCursor()
+			text = reverseString(text)
+			step := 0
+			for _, r := range text {
+				if string(r) == bracket.right {
+					stack++
+				} else if string(r) == bracket.left {
+					stack--
+				}
+				if stack == 0 {
+					// 比如这种情况 () 光标在括号中间
+					// stack == 0 的时候， step = 0 ，需要向左移动一格，所以还需要减一
+					return d.cursorPosition - step - 1
+				}
+				step++
+			}
+		}
+	}
+	return -1
+}
+
 // EmptyLineCountAtTheEnd 统计输入文本中底部空行数量
 func (d *Document) EmptyLineCountAtTheEnd() int {
 	count := 0
@@ -7,6 +7,13 @@ const (
 	Normal            LineMode = "normal"
 	IncrementalSearch LineMode = "incremental-search"
 	Complete          LineMode = "complete"
+	// CompletionQuery 询问是否列出全部补全，按键用来回答（见 CompletionDisplayReadline）
+	CompletionQuery LineMode = "completion-query"
+
+	// ViNormal ViInsert ViVisual vi 编辑模式下的 normal insert visual 模式
+	ViNormal LineMode = "vi-normal"
+	ViInsert LineMode = "vi-insert"
+	ViVisual LineMode = "vi-visual"
 )
 
 func (m LineMode) In(modes ...LineMode) bool {
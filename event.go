@@ -1,15 +1,54 @@
 package startprompt
 
+import "strings"
+
 type Event interface {
 	Type() EventType
 }
 
+// KeyModifier 跟按键一起按下的修饰键，比如 Ctrl-Right 的 Ctrl
+type KeyModifier uint8
+
+const (
+	KeyModShift KeyModifier = 1 << iota
+	KeyModAlt
+	KeyModCtrl
+)
+
+// Has 返回 true 表示按下了修饰键 mod
+func (m KeyModifier) Has(mod KeyModifier) bool {
+	return m&mod != 0
+}
+
+// prefix 返回修饰键在按键名字中的前缀，比如 Ctrl+Shift 返回 "ctrl_shift_"
+func (m KeyModifier) prefix() string {
+	var sb strings.Builder
+	for _, mod := range keyModifierNames {
+		if m.Has(mod.modifier) {
+			sb.WriteString(mod.name)
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+var keyModifierNames = []struct {
+	modifier KeyModifier
+	name     string
+}{
+	{KeyModCtrl, "ctrl"},
+	{KeyModAlt, "alt"},
+	{KeyModShift, "shift"},
+}
+
 // EventKey 代表键盘事件
 type EventKey struct {
 	cli       *CommandLine
 	tcli      *TCommandLine
 	data      []rune
 	eventType EventType
+	//    修饰键，只有方向键等导航键会有
+	modifiers KeyModifier
 }
 
 //goland:noinspection GoUnusedExportedFunction
@@ -26,6 +65,11 @@ func (ek *EventKey) Type() EventType {
 	return ek.eventType
 }
 
+// Modifiers 返回跟按键一起按下的修饰键
+func (ek *EventKey) Modifiers() KeyModifier {
+	return ek.modifiers
+}
+
 func (ek *EventKey) GetData() []rune {
 	return ek.data
 }
@@ -6,6 +6,56 @@ type EventHandler interface {
 	Handle(event Event)
 }
 
+// lineInitializer 可选接口，事件处理器实现后会在每次开始输入时收到新的 Line ，用来初始化编辑状态
+type lineInitializer interface {
+	initLine(line *Line)
+}
+
+// initHandlerLine 如果事件处理器实现了 lineInitializer ，调用 initLine
+func initHandlerLine(handler EventHandler, line *Line) {
+	if initializer, ok := handler.(lineInitializer); ok {
+		initializer.initLine(line)
+	}
+}
+
+// modifiedKeyMotion 返回跟修饰键一起按下的按键对应的光标移动，没有对应的移动时返回 nil
+//
+//	Ctrl 或者 Alt 加左右方向键按单词移动， Ctrl-Home Ctrl-End 移动到输入的开始、末尾
+//	只按住 Shift 时跟单独的按键一样移动
+func modifiedKeyMotion(eventType EventType, modifiers KeyModifier) func(l *Line) {
+	if modifiers.Has(KeyModCtrl) || modifiers.Has(KeyModAlt) {
+		switch eventType {
+		case EventTypeArrowLeft:
+			return (*Line).CursorWordBack
+		case EventTypeArrowRight:
+			return (*Line).CursorWordForward
+		case EventTypeHome:
+			return (*Line).Home
+		case EventTypeEnd:
+			return (*Line).End
+		}
+		return nil
+	}
+	if !modifiers.Has(KeyModShift) {
+		return nil
+	}
+	switch eventType {
+	case EventTypeArrowLeft:
+		return (*Line).CursorLeft
+	case EventTypeArrowRight:
+		return (*Line).CursorRight
+	case EventTypeArrowUp:
+		return (*Line).CursorUp
+	case EventTypeArrowDown:
+		return (*Line).CursorDown
+	case EventTypeHome:
+		return (*Line).Home
+	case EventTypeEnd:
+		return (*Line).End
+	}
+	return nil
+}
+
 type TBaseEventHandler struct {
 	//    最后处理的事件
 	lastEvent EventType
@@ -83,11 +133,29 @@ func (tb *TBaseEventHandler) HandleEventKey(ek *EventKey) {
 
 	tb.tcli.GetRenderer().TriggerEventKey()
 
+	//    询问是否列出全部补全时，按键用来回答
+	if tb.line.mode.Is(linemode.CompletionQuery) && answerCompletionQuery(tb.line, eventType, ek.GetData()) {
+		return
+	}
+
+	//    增量搜索时，除了搜索相关的按键，其他按键都会先结束搜索，再执行原本的操作
+	if tb.line.mode.Is(linemode.IncrementalSearch) && !isIncrementalSearchEvent(eventType) {
+		tb.line.AcceptSearch()
+	}
+
 	if tb.needsToSave(eventType) {
 		tb.line.SaveToUndoStack()
 	}
 
 	data := ek.GetData()
+	//    跟修饰键一起按下的按键，没有对应的操作时按照单独的按键处理
+	//    除了会替换或者删除选中文本的按键，其他按键都会取消选中，按住 Shift 移动光标时扩展选中
+	if !ek.Modifiers().Has(KeyModShift) && !isSelectionEvent(eventType) {
+		tb.line.ClearSelection()
+	}
+	if ek.Modifiers() != 0 && tb.handleModifiedKey(eventType, ek.Modifiers()) {
+		return
+	}
 	switch eventType {
 	case EventTypeCtrlSpace:
 		tb.CtrlSpace(data)
@@ -217,6 +285,20 @@ func (tb *TBaseEventHandler) HandleEventKey(ek *EventKey) {
 		tb.EscapeAction(data)
 	case EventTypeInsertChar:
 		tb.InsertChar(data)
+	case EventTypeMetaB:
+		tb.MetaB(data)
+	case EventTypeMetaD:
+		tb.MetaD(data)
+	case EventTypeMetaF:
+		tb.MetaF(data)
+	case EventTypeMetaW:
+		tb.MetaW(data)
+	case EventTypeMetaY:
+		tb.MetaY(data)
+	case EventTypeMetaBackspace:
+		tb.MetaBackspace(data)
+	case EventTypePaste:
+		tb.Paste(data)
 	}
 }
 
@@ -253,10 +335,18 @@ func (tb *TBaseEventHandler) CtrlF(_ []rune) {
 	tb.line.CursorRight()
 }
 func (tb *TBaseEventHandler) CtrlG(_ []rune) {
-
+	tb.line.CancelSearch()
 }
 func (tb *TBaseEventHandler) CtrlH(_ []rune) {
+	if tb.line.mode.Is(linemode.IncrementalSearch) {
+		tb.line.DeleteSearchCharacter()
+		return
+	}
 	tb.line.ToNormalMode()
+	if tb.line.HasSelection() {
+		tb.line.DeleteSelection()
+		return
+	}
 	tb.line.DeleteCharacterBeforeCursor(1)
 }
 func (tb *TBaseEventHandler) CtrlI(_ []rune) {
@@ -269,7 +359,7 @@ func (tb *TBaseEventHandler) CtrlJ(_ []rune) {
 }
 func (tb *TBaseEventHandler) CtrlK(_ []rune) {
 	tb.line.ToNormalMode()
-	tb.line.DeleteUntilEndOfLine()
+	tb.line.KillUntilEndOfLine()
 }
 func (tb *TBaseEventHandler) CtrlL(_ []rune) {
 	tb.tcli.GetRenderer().Clear()
@@ -288,27 +378,53 @@ func (tb *TBaseEventHandler) CtrlP(_ []rune) {
 	tb.line.AutoUp()
 }
 func (tb *TBaseEventHandler) CtrlQ(_ []rune) {}
-func (tb *TBaseEventHandler) CtrlR(_ []rune) {}
-func (tb *TBaseEventHandler) CtrlS(_ []rune) {}
+func (tb *TBaseEventHandler) CtrlR(_ []rune) {
+	tb.line.ReverseSearch()
+}
+func (tb *TBaseEventHandler) CtrlS(_ []rune) {
+	tb.line.ForwardSearch()
+}
 func (tb *TBaseEventHandler) CtrlT(_ []rune) {}
 func (tb *TBaseEventHandler) CtrlU(_ []rune) {
 	tb.line.ToNormalMode()
-	tb.line.DeleteFromStartOfLine()
+	tb.line.KillFromStartOfLine()
+}
+func (tb *TBaseEventHandler) CtrlV(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.PasteClipboard()
 }
-func (tb *TBaseEventHandler) CtrlV(_ []rune) {}
 func (tb *TBaseEventHandler) CtrlW(_ []rune) {
 	tb.line.ToNormalMode()
-	tb.line.DeleteWordBeforeCursor()
+	if tb.line.HasSelection() {
+		tb.line.CutSelection()
+		return
+	}
+	tb.line.KillWordBeforeCursor()
+}
+func (tb *TBaseEventHandler) CtrlX(_ []rune) {}
+func (tb *TBaseEventHandler) CtrlY(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.Yank()
 }
-func (tb *TBaseEventHandler) CtrlX(_ []rune)           {}
-func (tb *TBaseEventHandler) CtrlY(_ []rune)           {}
 func (tb *TBaseEventHandler) CtrlZ(_ []rune)           {}
 func (tb *TBaseEventHandler) CtrlBackslash(_ []rune)   {}
 func (tb *TBaseEventHandler) CtrlSquareClose(_ []rune) {}
-func (tb *TBaseEventHandler) CtrlCircumflex(_ []rune)  {}
-func (tb *TBaseEventHandler) CtrlUnderscore(_ []rune)  {}
+func (tb *TBaseEventHandler) CtrlCircumflex(_ []rune) {
+	tb.line.Redo()
+}
+func (tb *TBaseEventHandler) CtrlUnderscore(_ []rune) {
+	tb.line.Undo()
+}
 func (tb *TBaseEventHandler) Backspace(_ []rune) {
+	if tb.line.mode.Is(linemode.IncrementalSearch) {
+		tb.line.DeleteSearchCharacter()
+		return
+	}
 	tb.line.ToNormalMode()
+	if tb.line.HasSelection() {
+		tb.line.DeleteSelection()
+		return
+	}
 	tb.line.DeleteCharacterBeforeCursor(1)
 }
 func (tb *TBaseEventHandler) ArrowUp(_ []rune) {
@@ -318,12 +434,10 @@ func (tb *TBaseEventHandler) ArrowDown(_ []rune) {
 	tb.line.AutoDown()
 }
 func (tb *TBaseEventHandler) ArrowRight(_ []rune) {
-	tb.line.ToNormalMode()
-	tb.line.CursorRight()
+	tb.line.AutoRight()
 }
 func (tb *TBaseEventHandler) ArrowLeft(_ []rune) {
-	tb.line.ToNormalMode()
-	tb.line.CursorLeft()
+	tb.line.AutoLeft()
 }
 func (tb *TBaseEventHandler) Home(_ []rune) {
 	tb.line.ToNormalMode()
@@ -335,6 +449,10 @@ func (tb *TBaseEventHandler) End(_ []rune) {
 }
 func (tb *TBaseEventHandler) DeleteAction(_ []rune) {
 	tb.line.ToNormalMode()
+	if tb.line.HasSelection() {
+		tb.line.DeleteSelection()
+		return
+	}
 	tb.line.DeleteCharacterAfterCursor(1)
 }
 func (tb *TBaseEventHandler) PageUp(_ []rune)   {}
@@ -363,7 +481,38 @@ func (tb *TBaseEventHandler) F20(_ []rune)      {}
 func (tb *TBaseEventHandler) EscapeAction(_ []rune) {
 	tb.line.CancelComplete()
 }
+func (tb *TBaseEventHandler) MetaB(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.CursorWordBack()
+}
+func (tb *TBaseEventHandler) MetaD(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.KillWord()
+}
+func (tb *TBaseEventHandler) MetaF(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.CursorWordForward()
+}
+func (tb *TBaseEventHandler) MetaW(_ []rune) {
+	tb.line.CopySelection()
+}
+func (tb *TBaseEventHandler) MetaY(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.YankPop()
+}
+func (tb *TBaseEventHandler) MetaBackspace(_ []rune) {
+	tb.line.ToNormalMode()
+	tb.line.KillWordBeforeCursor()
+}
+func (tb *TBaseEventHandler) Paste(data []rune) {
+	tb.line.ToNormalMode()
+	tb.line.PasteText(data)
+}
 func (tb *TBaseEventHandler) InsertChar(data []rune) {
+	if tb.line.mode.Is(linemode.IncrementalSearch) {
+		tb.line.InsertSearchText(data)
+		return
+	}
 	tb.line.ToNormalMode()
 	tb.line.InsertText(data, true)
 }
@@ -375,9 +524,30 @@ func (tb *TBaseEventHandler) enter() {
 	}
 }
 
+// handleModifiedKey 处理跟修饰键一起按下的按键，返回 false 表示没有对应的操作
+//
+//	按住 Shift 时光标移动会扩展选中区域
+func (tb *TBaseEventHandler) handleModifiedKey(eventType EventType, modifiers KeyModifier) bool {
+	move := modifiedKeyMotion(eventType, modifiers)
+	if move == nil {
+		return false
+	}
+	tb.line.ToNormalMode()
+	if modifiers.Has(KeyModShift) {
+		tb.line.ExtendSelection(move)
+	} else {
+		move(tb.line)
+	}
+	return true
+}
+
 func (tb *TBaseEventHandler) needsToSave(event EventType) bool {
 	// 用户输入字符时不进行保存，用户输入字符后再进行保存
 	// 这样一次可以撤销用户多次输入，而不是撤销一个个字符
+	// 撤销和恢复操作本身也不进行保存
+	if event == EventTypeCtrlUnderscore || event == EventTypeCtrlCircumflex {
+		return false
+	}
 	return !(event == EventTypeInsertChar && tb.lastEvent == EventTypeInsertChar)
 }
 
@@ -387,11 +557,8 @@ func (tb *TBaseEventHandler) tab() {
 		line.AcceptComplete()
 		return
 	}
-	if !line.Complete() {
-		line.CompleteNext(1)
-		// 如果没有补全，插入 4 个空格
-		if line.mode.Is(linemode.Normal) {
-			line.InsertText([]rune("    "), true)
-		}
+	// 如果没有补全，插入 4 个空格
+	if !line.Complete() && !line.startComplete(true) {
+		line.InsertText([]rune("    "), true)
 	}
 }
@@ -1,6 +1,10 @@
 package startprompt
 
-import "github.com/gdamore/tcell/v2"
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 type EventType int
 
@@ -81,12 +85,73 @@ var eventTypeStr = []string{
 	"<mouse_move>",
 	"<mouse_dblclick>",
 	"<mouse_triple_click>",
+	"<meta_y>",
+	"<meta_b>",
+	"<meta_d>",
+	"<meta_f>",
+	"<meta_backspace>",
+	"<paste>",
+	"<meta_w>",
 }
 
 func (a EventType) String() string {
 	return eventTypeStr[a]
 }
 
+// parseEventType 根据 EventType.String() 的结果返回对应的 EventType
+func parseEventType(s string) (EventType, bool) {
+	for i, str := range eventTypeStr {
+		if str == s {
+			return EventType(i), true
+		}
+	}
+	return 0, false
+}
+
+// parseKeyName 解析按键名字，比如 "<ctrl_a>" "<ctrl_shift_arrow_left>"
+//
+//	方向键等导航键可以加上 ctrl_ alt_ shift_ 前缀表示跟修饰键一起按下
+func parseKeyName(s string) (EventType, KeyModifier, bool) {
+	if eventType, found := parseEventType(s); found {
+		return eventType, 0, true
+	}
+	if !strings.HasPrefix(s, "<") {
+		return 0, 0, false
+	}
+	var modifiers KeyModifier
+	name := s[1:]
+	for _, mod := range keyModifierNames {
+		if strings.HasPrefix(name, mod.name+"_") {
+			modifiers |= mod.modifier
+			name = name[len(mod.name)+1:]
+		}
+	}
+	eventType, found := parseEventType("<" + name)
+	if !found || modifiers == 0 || !isModifiableEvent(eventType) {
+		return 0, 0, false
+	}
+	return eventType, modifiers, true
+}
+
+// keyName 返回按键名字，跟 parseKeyName 相反
+func keyName(eventType EventType, modifiers KeyModifier) string {
+	name := eventType.String()
+	if modifiers == 0 {
+		return name
+	}
+	return "<" + modifiers.prefix() + name[1:]
+}
+
+// isModifiableEvent 返回 true 表示按键可以跟修饰键一起按下
+func isModifiableEvent(eventType EventType) bool {
+	switch eventType {
+	case EventTypeArrowUp, EventTypeArrowDown, EventTypeArrowRight, EventTypeArrowLeft,
+		EventTypeHome, EventTypeEnd, EventTypePageUp, EventTypePageDown, EventTypeDeleteAction:
+		return true
+	}
+	return false
+}
+
 //goland:noinspection GoUnusedConst
 const (
 	EventTypeCtrlA EventType = iota
@@ -179,10 +244,82 @@ const (
 	EventTypeMouseDblclick
 	// EventTypeMouseTripleClick 鼠标左键三击
 	EventTypeMouseTripleClick
+	// EventTypeMetaY Meta-Y (Alt-Y)
+	EventTypeMetaY
+	// EventTypeMetaB Meta-B (Alt-B)
+	EventTypeMetaB
+	// EventTypeMetaD Meta-D (Alt-D)
+	EventTypeMetaD
+	// EventTypeMetaF Meta-F (Alt-F)
+	EventTypeMetaF
+	// EventTypeMetaBackspace Meta-Backspace (Alt-Backspace)
+	EventTypeMetaBackspace
+	// EventTypePaste 粘贴文本（终端开启 bracketed paste 时），事件数据是粘贴的所有文本
+	EventTypePaste
+	// EventTypeMetaW Meta-W (Alt-W)
+	EventTypeMetaW
 
 	EventTypeTab = EventTypeCtrlI
 )
 
+// isSelectionEvent 返回 true 表示该事件会替换或者删除选中的文本，其他事件会先取消选中
+func isSelectionEvent(eventType EventType) bool {
+	switch eventType {
+	case EventTypeInsertChar, EventTypePaste,
+		EventTypeCtrlH, EventTypeBackspace, EventTypeDeleteAction,
+		EventTypeCtrlV, EventTypeCtrlW, EventTypeMetaW:
+		return true
+	}
+	return false
+}
+
+// isIncrementalSearchEvent 返回 true 表示该事件在增量搜索时由搜索处理
+func isIncrementalSearchEvent(eventType EventType) bool {
+	switch eventType {
+	case EventTypeCtrlR, EventTypeCtrlS, EventTypeCtrlG,
+		EventTypeCtrlH, EventTypeBackspace, EventTypeInsertChar:
+		return true
+	}
+	return false
+}
+
+// tmetaKeyMapping 按住 Alt 时 tcell rune 事件映射
+var tmetaKeyMapping = map[rune]EventType{
+	'b': EventTypeMetaB,
+	'd': EventTypeMetaD,
+	'f': EventTypeMetaF,
+	'w': EventTypeMetaW,
+	'y': EventTypeMetaY,
+}
+
+// tkeyModifiers 将 tcell 修饰键转换成 KeyModifier
+func tkeyModifiers(mask tcell.ModMask) KeyModifier {
+	var modifiers KeyModifier
+	if mask&tcell.ModShift != 0 {
+		modifiers |= KeyModShift
+	}
+	if mask&tcell.ModAlt != 0 {
+		modifiers |= KeyModAlt
+	}
+	if mask&tcell.ModCtrl != 0 {
+		modifiers |= KeyModCtrl
+	}
+	return modifiers
+}
+
+// metaKeyOf 返回 Meta 组合键中跟 Alt 一起按下的按键，比如 Meta-B 返回字符 b
+func metaKeyOf(eventType EventType) (EventType, []rune, bool) {
+	if eventType == EventTypeMetaBackspace {
+		return EventTypeBackspace, []rune{'\x7f'}, true
+	}
+	for r, metaEventType := range tmetaKeyMapping {
+		if metaEventType == eventType {
+			return EventTypeInsertChar, []rune{r}, true
+		}
+	}
+	return EventTypeInsertChar, nil, false
+}
+
 // tkeyMapping tcell key 事件映射
 var tkeyMapping = map[tcell.Key]EventType{
 	tcell.KeyRune:    EventTypeInsertChar,
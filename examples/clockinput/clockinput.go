@@ -10,7 +10,7 @@ import (
 
 /*
 动态提示符例子
-在提示符中展示当前时间，可以看到提示符随时间的变化
+在提示符和底部工具栏中展示当前时间，可以看到提示符和工具栏随时间的变化
 */
 
 type ClockPrompt struct {
@@ -29,9 +29,18 @@ func NewClockPrompt(_ startprompt.Code) startprompt.Prompt {
 	return &ClockPrompt{startprompt.BasePrompt{}}
 }
 
+// clockToolbar 底部工具栏展示日期和时间
+func clockToolbar() []token.Token {
+	now := time.Now()
+	return []token.Token{
+		token.NewToken(token.Toolbar, now.Format(" 2006-01-02 15:04:05 ")),
+	}
+}
+
 func main() {
 	c, err := startprompt.NewCommandLine(&startprompt.CommandLineOption{
 		PromptFactory: NewClockPrompt,
+		Toolbar:       clockToolbar,
 	})
 	if err != nil {
 		fmt.Printf("failed to startprompt.NewCommandLine: %v\n", err)
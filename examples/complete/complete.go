@@ -7,6 +7,7 @@ package main
 - 如果只有一个匹配的补全，补全文本直接添加在后面
 - 如果有多个，展示所有补全， Ctrl-P 和 Ctrl-N 上下移动选择补全项，按 Tab 则会使用当前选中的补全
 - 按 Esc 或者 Ctrl+[ 退出补全（注意：需要按两下）
+- 补全列表使用模糊匹配，比如 "bf" 可以匹配 "butterfly" ，匹配的字符会高亮
 */
 
 import (
@@ -62,33 +63,40 @@ func newCompleteCode(document *startprompt.Document) startprompt.Code {
 func (c *AnimalCode) GetTokens() []token.Token {
 	return []token.Token{
 		{
-			token.Unspecific,
-			c.document.Text(),
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
 		},
 	}
 }
 
 func (c *AnimalCode) Complete() string {
-	completions := c.GetCompletions()
-	if len(completions) == 1 {
-		return completions[0].Suffix
+	word := c.document.GetWordBeforeCursor()
+	var matches []string
+	for _, animal := range c.animals {
+		if strings.HasPrefix(animal, word) {
+			matches = append(matches, animal)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0][len(word):]
 	}
 	return ""
 }
 
+// GetCompletions 模糊匹配光标前的单词，比如 "bf" 可以补全 "butterfly"
 func (c *AnimalCode) GetCompletions() []*startprompt.Completion {
 	word := c.document.GetWordBeforeCursor()
 
 	var completions []*startprompt.Completion
-	for _, animal := range c.animals {
-		if strings.HasPrefix(animal, word) {
-			cp := &startprompt.Completion{
-				Display:     animal,
-				Suffix:      animal[len(word):],
-				DisplayMeta: "animal",
-			}
-			completions = append(completions, cp)
+	for _, result := range startprompt.FuzzyFilter(word, c.animals) {
+		cp := &startprompt.Completion{
+			Display:        result.Text,
+			Text:           result.Text,
+			StartPosition:  -len([]rune(word)),
+			DisplayMeta:    "animal",
+			MatchPositions: result.Positions,
 		}
+		completions = append(completions, cp)
 	}
 	return completions
 }
@@ -0,0 +1,54 @@
+package main
+
+/*
+展示可组合的补全器
+
+按下 Tab 根据命令树补全，比如 "git commit --amend"
+- git add 后面补全选项和文件路径
+- 第一个单词使用模糊匹配，比如 "gt" 可以匹配 "git"
+*/
+
+import (
+	"fmt"
+
+	"github.com/yetsing/startprompt"
+)
+
+func main() {
+	completer := startprompt.NewNestedCompleter(map[string]interface{}{
+		"git": map[string]interface{}{
+			"add": startprompt.NewMergedCompleter(
+				startprompt.NewWordCompleter([]string{"--all", "--patch"}),
+				startprompt.NewPathCompleter(),
+			),
+			"commit":   []string{"--amend", "--message", "--no-verify"},
+			"checkout": []string{"main", "develop"},
+			"status":   nil,
+		},
+		"exit": nil,
+	}, false)
+	//    第一个单词模糊匹配，后面的单词交给命令树
+	commands := startprompt.NewFuzzyCompleter(startprompt.NewWordCompleter([]string{"git", "exit"}))
+	c, err := startprompt.NewCommandLine(&startprompt.CommandLineOption{
+		CodeFactory: startprompt.WithCompleter(nil, startprompt.CompleterFunc(
+			func(document *startprompt.Document) []*startprompt.Completion {
+				if document.GetWordBeforeCursor() == document.TextBeforeCursor() {
+					return commands.GetCompletions(document)
+				}
+				return completer.GetCompletions(document)
+			},
+		)),
+	})
+	if err != nil {
+		fmt.Printf("failed to startprompt.NewCommandLine: %v\n", err)
+		return
+	}
+	defer c.Close()
+	c.Println("Press tab to complete command")
+	line, err := c.ReadInput()
+	if err != nil {
+		c.Printf("ReadInput error: %v\n", err)
+		return
+	}
+	c.Println("echo:", line)
+}
@@ -0,0 +1,72 @@
+package main
+
+/*
+展示文件路径补全
+
+按下 Tab 补全光标前的路径，目录以 / 结尾，右边显示文件类型
+- 支持 ~ 展开为用户目录
+- 输入 . 开头时才显示隐藏文件
+- 文件名中的空格会用 \ 转义
+*/
+
+import (
+	"fmt"
+
+	"github.com/yetsing/startprompt"
+	"github.com/yetsing/startprompt/token"
+)
+
+type PathCode struct {
+	document      *startprompt.Document
+	pathCompleter *startprompt.PathCompleter
+}
+
+func newPathCode(document *startprompt.Document) startprompt.Code {
+	return &PathCode{
+		document:      document,
+		pathCompleter: startprompt.NewPathCompleter(),
+	}
+}
+
+func (c *PathCode) GetTokens() []token.Token {
+	return []token.Token{
+		{
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
+		},
+	}
+}
+
+func (c *PathCode) Complete() string {
+	return ""
+}
+
+func (c *PathCode) GetCompletions() []*startprompt.Completion {
+	return c.pathCompleter.GetCompletions(c.document)
+}
+
+func (c *PathCode) ContinueInput() bool {
+	return false
+}
+
+func (c *PathCode) CompleteAfterInsertText() bool {
+	return false
+}
+
+func main() {
+	c, err := startprompt.NewCommandLine(&startprompt.CommandLineOption{
+		CodeFactory: newPathCode,
+	})
+	if err != nil {
+		fmt.Printf("failed to startprompt.NewCommandLine: %v\n", err)
+		return
+	}
+	defer c.Close()
+	c.Println("Press tab to complete path")
+	line, err := c.ReadInput()
+	if err != nil {
+		c.Printf("ReadInput error: %v\n", err)
+		return
+	}
+	c.Println("echo:", line)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-runewidth"
 	"github.com/yetsing/startprompt"
@@ -16,7 +17,7 @@ import (
 */
 
 var inputCount = 1
-var schema = map[token.TokenType]terminalcolor.Style{
+var schema = map[token.TokenType]*terminalcolor.ColorStyle{
 	token.Prompt: terminalcolor.NewFgColorStyleHex("#004400"),
 }
 
@@ -44,16 +45,28 @@ func (p *Prompt) GetSecondLinePrefix() []token.Token {
 	// 输出类似这样的 "...: " ，宽度跟默认提示符一样
 	return []token.Token{
 		{
-			token.PromptSecondLinePrefix,
-			startprompt.RepeatString(" ", spaces),
+			Type:    token.PromptSecondLinePrefix,
+			Literal: startprompt.RepeatString(" ", spaces),
 		},
 		{
-			token.PromptSecondLinePrefix,
-			startprompt.RepeatString(".", 3) + ": ",
+			Type:    token.PromptSecondLinePrefix,
+			Literal: startprompt.RepeatString(".", 3) + ": ",
 		},
 	}
 }
 
+// GetRightPrompt 右侧提示符显示当前时间
+func (p *Prompt) GetRightPrompt() []token.Token {
+	tk := token.NewToken(token.Prompt, time.Now().Format("15:04:05"))
+	return []token.Token{tk}
+}
+
+// GetTransientPrompt 确定输入后使用简短的提示符
+func (p *Prompt) GetTransientPrompt() []token.Token {
+	tk := token.NewToken(token.Prompt, fmt.Sprintf("[%d]: ", inputCount))
+	return []token.Token{tk}
+}
+
 type MultilineCode struct {
 	document *startprompt.Document
 }
@@ -65,8 +78,8 @@ func newMultilineCode(document *startprompt.Document) startprompt.Code {
 func (c *MultilineCode) GetTokens() []token.Token {
 	return []token.Token{
 		{
-			token.Unspecific,
-			c.document.Text(),
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
 		},
 	}
 }
@@ -24,6 +24,10 @@ function main() {
   go run ./examples/echo/echo.go
   echo "============== multiline ==============="
   go run ./examples/multiline/multiline.go
+  echo "============== nestedcomplete =========="
+  go run ./examples/nestedcomplete/nestedcomplete.go
+  echo "============== pathcomplete ============"
+  go run ./examples/pathcomplete/pathcomplete.go
   echo "============== persistenthistory ======="
   go run ./examples/persistenthistory/persistenthistory.go
   echo "============== prompt =================="
@@ -62,8 +62,8 @@ func newCompleteCode(document *startprompt.Document) startprompt.Code {
 func (c *AnimalCode) GetTokens() []token.Token {
 	return []token.Token{
 		{
-			token.Unspecific,
-			c.document.Text(),
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
 		},
 	}
 }
@@ -24,8 +24,8 @@ func newMultilineCode(document *startprompt.Document) startprompt.Code {
 func (c *MultilineCode) GetTokens() []token.Token {
 	return []token.Token{
 		{
-			token.Unspecific,
-			c.document.Text(),
+			Type:    token.Unspecific,
+			Literal: c.document.Text(),
 		},
 	}
 }
@@ -0,0 +1,175 @@
+package startprompt
+
+import (
+	"sort"
+	"unicode"
+)
+
+/*
+模糊匹配，类似 fzf 和 VS Code 的补全过滤
+pattern 的字符按顺序出现在文本中即为匹配，比如 "fb" 匹配 "FooBar" ，
+单词开头、连续的匹配得分更高，中间跳过的字符会扣分
+*/
+
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyScoreGapStart    = -3
+	fuzzyScoreGapExtend   = -1
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusCamelCase   = 7
+	fuzzyBonusConsecutive = 4
+	//    第一个字符的加分翻倍，开头匹配的优先
+	fuzzyBonusFirstCharMultiplier = 2
+)
+
+// FuzzyMatchResult 模糊匹配结果
+type FuzzyMatchResult struct {
+	// Text 匹配的文本
+	Text string
+	// Score 得分，越大越匹配
+	Score int
+	// Positions 匹配字符在 Text 中的位置（字符索引，从小到大）
+	Positions []int
+}
+
+// FuzzyMatch 模糊匹配 pattern 和 text ，不匹配时 matched 为 false
+//
+//	pattern 全是小写时忽略大小写，否则区分大小写（跟 fzf 的 smart case 一样）
+//	pattern 为空时匹配任意文本，得分为 0
+func FuzzyMatch(pattern string, text string) (score int, positions []int, matched bool) {
+	patternRunes := []rune(pattern)
+	textRunes := []rune(text)
+	if len(patternRunes) == 0 {
+		return 0, nil, true
+	}
+	if len(patternRunes) > len(textRunes) {
+		return 0, nil, false
+	}
+	caseSensitive := false
+	for _, r := range patternRunes {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	equal := func(p rune, t rune) bool {
+		if caseSensitive {
+			return p == t
+		}
+		return p == unicode.ToLower(t)
+	}
+
+	m, n := len(patternRunes), len(textRunes)
+	bonuses := make([]int, n)
+	for j := range textRunes {
+		bonuses[j] = fuzzyBonusAt(textRunes, j)
+	}
+	//    scores[i][j] 表示 pattern[i] 匹配 text[j] 时 pattern[:i+1] 的最高得分，不能匹配为 noScore
+	//    prev[i][j] 记录 pattern[i-1] 匹配的位置，用来回溯出匹配位置
+	//    chunkBonus[i][j] 记录连续匹配第一个字符的加分，连续匹配的字符沿用这个加分（跟 fzf 一样）
+	const noScore = -1 << 30
+	scores := make([][]int, m)
+	prev := make([][]int, m)
+	chunkBonus := make([][]int, m)
+	for i := 0; i < m; i++ {
+		scores[i] = make([]int, n)
+		prev[i] = make([]int, n)
+		chunkBonus[i] = make([]int, n)
+		//    跳过若干字符后的最高得分和对应位置
+		gapScore, gapFrom := noScore, -1
+		for j := 0; j < n; j++ {
+			scores[i][j] = noScore
+			if i > 0 && j >= 2 {
+				//    所有候选位置跳过的字符多了一个
+				if gapScore != noScore {
+					gapScore += fuzzyScoreGapExtend
+				}
+				if k := j - 2; scores[i-1][k] != noScore && scores[i-1][k]+fuzzyScoreGapStart > gapScore {
+					gapScore, gapFrom = scores[i-1][k]+fuzzyScoreGapStart, k
+				}
+			}
+			if !equal(patternRunes[i], textRunes[j]) {
+				continue
+			}
+			bonus := bonuses[j]
+			if i == 0 {
+				scores[i][j] = fuzzyScoreMatch + bonus*fuzzyBonusFirstCharMultiplier
+				prev[i][j] = -1
+				chunkBonus[i][j] = bonus
+				continue
+			}
+			best, from, bestBonus := gapScore, gapFrom, bonus
+			if j >= 1 && scores[i-1][j-1] != noScore {
+				//    连续匹配至少有连续匹配的加分
+				consecutiveBonus := maxInt(fuzzyBonusConsecutive, chunkBonus[i-1][j-1], bonus)
+				if consecutive := scores[i-1][j-1] + consecutiveBonus - bonus; consecutive >= best {
+					best, from, bestBonus = consecutive, j-1, consecutiveBonus
+				}
+			}
+			if best == noScore {
+				continue
+			}
+			scores[i][j] = best + fuzzyScoreMatch + bonus
+			prev[i][j] = from
+			chunkBonus[i][j] = bestBonus
+		}
+	}
+
+	end := -1
+	for j := 0; j < n; j++ {
+		if scores[m-1][j] != noScore && (end == -1 || scores[m-1][j] > scores[m-1][end]) {
+			end = j
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	positions = make([]int, m)
+	for i, j := m-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = prev[i][j]
+	}
+	return scores[m-1][end], positions, true
+}
+
+// fuzzyBonusAt 返回 text[index] 位置匹配的加分，单词开头的位置加分
+func fuzzyBonusAt(text []rune, index int) int {
+	if index == 0 {
+		return fuzzyBonusBoundary
+	}
+	prev, cur := text[index-1], text[index]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(cur) || unicode.IsDigit(cur)):
+		//    分隔符后面，比如 "foo_bar" "foo/bar" "foo bar" 中的 b
+		return fuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur), !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		//    驼峰，比如 "fooBar" 中的 B
+		return fuzzyBonusCamelCase
+	}
+	return 0
+}
+
+// FuzzyFilter 返回 items 中跟 pattern 模糊匹配的文本，按照得分从高到低排序
+//
+//	得分相同时较短的文本在前面，长度也相同时保持原来的顺序
+func FuzzyFilter(pattern string, items []string) []*FuzzyMatchResult {
+	var results []*FuzzyMatchResult
+	for _, item := range items {
+		score, positions, matched := FuzzyMatch(pattern, item)
+		if !matched {
+			continue
+		}
+		results = append(results, &FuzzyMatchResult{
+			Text:      item,
+			Score:     score,
+			Positions: positions,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return len(results[i].Text) < len(results[j].Text)
+	})
+	return results
+}
@@ -0,0 +1,57 @@
+package startprompt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		text      string
+		matched   bool
+		positions string
+	}{
+		{"", "abc", true, "[]"},
+		{"abc", "abc", true, "[0 1 2]"},
+		{"fb", "FooBar", true, "[0 3]"},
+		{"fb", "foo_bar", true, "[0 4]"},
+		//    优先匹配单词开头
+		{"b", "abc_bcd", true, "[4]"},
+		//    优先连续匹配
+		{"bar", "b_a_r_bar", true, "[6 7 8]"},
+		{"abd", "abc", false, "[]"},
+		{"abcd", "abc", false, "[]"},
+		//    pattern 中有大写时区分大小写
+		{"B", "abc", false, "[]"},
+		{"B", "aBc", true, "[1]"},
+	}
+	for _, tt := range tests {
+		_, positions, matched := FuzzyMatch(tt.pattern, tt.text)
+		testBoolEqual(t, tt.matched, matched)
+		if matched {
+			testStringEqual(t, tt.positions, fmt.Sprint(positions))
+		}
+	}
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	results := FuzzyFilter("co", []string{"decor", "chocolate", "cow", "copy", "dog"})
+	var texts []string
+	for _, result := range results {
+		texts = append(texts, result.Text)
+	}
+	testStringEqual(t, "[cow copy chocolate decor]", fmt.Sprint(texts))
+}
+
+func TestCompletionMenu_MatchTokens(t *testing.T) {
+	menu := &cCompletionMenu{}
+	completion := &Completion{Display: "foobar", MatchPositions: []int{0, 3, 4}}
+	var got []string
+	for _, tk := range menu.getMenuItemTokens(completion, false, 8) {
+		got = append(got, fmt.Sprintf("%s:%q", tk.Type, tk.Literal))
+	}
+	testStringEqual(t,
+		`[completionmenu.completion:" " completionmenu.match:"f" completionmenu.completion:"oo" completionmenu.match:"ba" completionmenu.completion:"r  "]`,
+		fmt.Sprint(got))
+}
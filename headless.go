@@ -0,0 +1,145 @@
+package startprompt
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/yetsing/startprompt/vterminal"
+)
+
+/*
+无终端运行命令行，按脚本输入按键，用于测试 Code 和 Prompt 等实现
+*/
+
+// HeadlessResult 无终端运行的结果
+type HeadlessResult struct {
+	// Text 用户输入的文本，输入没有结束时是当前输入的文本
+	Text string
+	// Accepted 用户是否确定了本次输入（一般是按下 Enter）
+	Accepted bool
+	// Err 输入结束时返回的错误，比如 ExitError AbortError
+	Err error
+	// Terminal 虚拟终端，保存了渲染后的屏幕内容
+	Terminal *vterminal.Terminal
+}
+
+// Screen 返回屏幕的文本
+func (hr *HeadlessResult) Screen() string {
+	return hr.Terminal.PlainText()
+}
+
+// StyledScreen 返回带样式标记的屏幕文本，格式见 vterminal.Terminal.StyledText
+func (hr *HeadlessResult) StyledScreen() string {
+	return hr.Terminal.StyledText()
+}
+
+// RunHeadless 在虚拟终端中运行 CommandLine ，按脚本输入按键，直到脚本结束或者本次输入结束
+// 窗口大小由 option.SizeFunc 决定，默认是 80x24 ； option.Input option.Output 会被忽略
+//
+//	脚本中用 <name> 表示按键，比如 "abc<ctrl_a><tab><enter>" ，name 跟 EventType.String() 一致，
+//	方向键等按键可以加上修饰键前缀，比如 <ctrl_arrow_left> <shift_home>
+//	另外支持 <enter> <tab> <esc> <space> ，字符 < 本身用 <lt> 表示
+//	每个按键以及两个按键之间的文本都会作为一次输入，处理后渲染一次屏幕
+func RunHeadless(option *CommandLineOption, script string) (*HeadlessResult, error) {
+	inputs, err := parseKeyScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	actualOption := defaultCommandLineOption.copy()
+	//    每次运行都使用新的历史和剪贴板，避免互相影响
+	actualOption.History = NewMemHistory()
+	actualOption.Clipboard = NewMemClipboard()
+	actualOption.SizeFunc = func() (int, int) {
+		return 80, 24
+	}
+	if option != nil {
+		actualOption.update(option)
+	}
+	width, height := actualOption.SizeFunc()
+	terminal := vterminal.New(width, height)
+	actualOption.Input = nil
+	actualOption.Output = terminal
+
+	c := &CommandLine{
+		writer:         bufio.NewWriter(terminal),
+		inputFd:        -1,
+		option:         actualOption,
+		isReadingInput: true,
+		killRing:       newKillRing(),
+		invokeQueue:    newInvokeQueue(),
+	}
+	is, resetFunc := c.startInput()
+	result := &HeadlessResult{Terminal: terminal}
+	for _, runes := range inputs {
+		is.FeedRunes(runes)
+		//    单独的 Esc 需要超时才能识别
+		is.FeedTimeout()
+		if done, inputText, err := c.handleFlags(resetFunc); done {
+			result.Text = inputText
+			result.Accepted = c.acceptFlag
+			result.Err = err
+			return result, nil
+		}
+		c.listCompletions()
+		c.renderer.render(c.line.GetRenderContext(), false, false)
+	}
+	result.Text = c.line.text()
+	return result, nil
+}
+
+// keyAliases 脚本中按键的别名
+var keyAliases = map[string]string{
+	"<enter>": "\r",
+	"<tab>":   "\t",
+	"<esc>":   "\x1b",
+	"<space>": " ",
+	"<lt>":    "<",
+}
+
+// parseKeyScript 解析按键脚本，返回每次输入的字符
+func parseKeyScript(script string) ([][]rune, error) {
+	var inputs [][]rune
+	var text []rune
+	for len(script) > 0 {
+		end := strings.IndexByte(script, '>')
+		if script[0] != '<' || end == -1 {
+			r := []rune(script)[0]
+			text = append(text, r)
+			script = script[len(string(r)):]
+			continue
+		}
+		name := script[:end+1]
+		key, found := keyAliases[name]
+		if !found {
+			eventType, modifiers, ok := parseKeyName(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown key %s", name)
+			}
+			if modifiers != 0 {
+				key, found = modifiedKeySequence(eventType, modifiers)
+			} else {
+				key, found = keySequence(eventType)
+			}
+			if !found {
+				return nil, fmt.Errorf("key %s has no input sequence", name)
+			}
+		}
+		if len(text) > 0 {
+			inputs = append(inputs, text)
+			text = nil
+		}
+		if name == "<lt>" || name == "<space>" {
+			//    普通字符，跟前后的文本一起输入
+			text = append(text, []rune(key)...)
+		} else {
+			inputs = append(inputs, []rune(key))
+		}
+		script = script[end+1:]
+	}
+	if len(text) > 0 {
+		inputs = append(inputs, text)
+	}
+	return inputs, nil
+}
@@ -0,0 +1,319 @@
+package startprompt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yetsing/startprompt/token"
+)
+
+func TestRunHeadless(t *testing.T) {
+	tests := []struct {
+		script   string
+		text     string
+		accepted bool
+		screen   string
+	}{
+		{"abc<ctrl_a>x<enter>", "xabc", true, "> xabc"},
+		{"abc<ctrl_h><lt>d", "ab<d", false, "> ab<d"},
+		{"hello<ctrl_a><ctrl_k>", "", false, ">"},
+		{"one two<ctrl_w><ctrl_underscore>", "one two", false, "> one two"},
+		{"one two<ctrl_w><ctrl_underscore><ctrl_circumflex>", "one ", false, "> one"},
+		{"one two<meta_b><meta_b>x", "xone two", false, "> xone two"},
+		{"one two<shift_arrow_left><shift_arrow_left><meta_w><end><ctrl_v>", "one twowo", false, "> one twowo"},
+		{"one two<ctrl_shift_arrow_left><ctrl_w><home><ctrl_v>", "twoone ", false, "> twoone"},
+		{"one two<ctrl_a><meta_f>x", "one xtwo", false, "> one xtwo"},
+		{"one two<ctrl_a><meta_d><ctrl_y><ctrl_y>", "one one two", false, "> one one two"},
+		{"one two<meta_backspace>", "one ", false, "> one"},
+		{"one two<ctrl_arrow_left>x", "one xtwo", false, "> one xtwo"},
+		{"one two<ctrl_home><ctrl_arrow_right>x", "one xtwo", false, "> one xtwo"},
+		{"one two<alt_arrow_left><shift_arrow_left>x", "onextwo", false, "> onextwo"},
+		{"one two<shift_home><backspace>x", "x", false, "> x"},
+		{"one two<ctrl_shift_arrow_left><delete_action>", "one ", false, "> one"},
+		{"one two<shift_arrow_left><arrow_left>x", "one txwo", false, "> one txwo"},
+	}
+	for _, tt := range tests {
+		result, err := RunHeadless(&CommandLineOption{
+			SizeFunc: func() (int, int) { return 40, 10 },
+		}, tt.script)
+		if err != nil {
+			t.Fatalf("script=%q err=%v", tt.script, err)
+		}
+		if result.Text != tt.text {
+			t.Errorf("script=%q text want=%q got=%q", tt.script, tt.text, result.Text)
+		}
+		if result.Accepted != tt.accepted {
+			t.Errorf("script=%q accepted want=%v got=%v", tt.script, tt.accepted, result.Accepted)
+		}
+		screen := strings.Split(result.Screen(), "\n")[0]
+		if screen != tt.screen {
+			t.Errorf("script=%q screen want=%q got=%q", tt.script, tt.screen, screen)
+		}
+	}
+}
+
+func TestRunHeadless_BracketedPaste(t *testing.T) {
+	result, err := RunHeadless(&CommandLineOption{AutoIndent: true}, "> \x1b[200~one\r  two\r\x1b[201~three")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "> one\n  two\nthree", result.Text)
+	if result.Accepted {
+		t.Errorf("paste should not accept input")
+	}
+}
+
+func TestRunHeadless_Selection(t *testing.T) {
+	result, err := RunHeadless(nil, "one two<shift_arrow_left><shift_arrow_left>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "> one t{bg=238}wo{}", result.StyledScreen())
+}
+
+// _TestValidateCode 输入中不能有数字
+type _TestValidateCode struct {
+	_BaseCode
+}
+
+func (c *_TestValidateCode) Validate() *ValidationError {
+	index := strings.IndexAny(c.document.Text(), "0123456789")
+	if index == -1 {
+		return nil
+	}
+	return &ValidationError{Position: index, Message: "digit not allowed"}
+}
+
+func TestRunHeadless_Validator(t *testing.T) {
+	option := &CommandLineOption{
+		CodeFactory: func(document *Document) Code {
+			return &_TestValidateCode{_BaseCode{document: document}}
+		},
+	}
+	result, err := RunHeadless(option, "ab1cd<enter>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if result.Accepted {
+		t.Fatalf("invalid input should not be accepted")
+	}
+	testStringEqual(t, "> ab1cd\ndigit not allowed", result.Screen())
+	//    光标移动到错误的位置，修改文本后错误信息消失
+	result, _ = RunHeadless(option, "ab1cd<enter><delete_action>")
+	testStringEqual(t, "> abcd", result.Screen())
+	result, _ = RunHeadless(option, "ab1cd<enter><delete_action><enter>")
+	if !result.Accepted || result.Text != "abcd" {
+		t.Errorf("expected accepted abcd, got %v %q", result.Accepted, result.Text)
+	}
+}
+
+type _TestRightPrompt struct {
+	BasePrompt
+}
+
+func (p *_TestRightPrompt) GetRightPrompt() []token.Token {
+	return []token.Token{token.NewToken(token.Prompt, "[main]")}
+}
+
+func TestRunHeadless_RightPrompt(t *testing.T) {
+	option := &CommandLineOption{
+		PromptFactory: func(code Code) Prompt {
+			return &_TestRightPrompt{}
+		},
+		SizeFunc: func() (int, int) {
+			return 20, 5
+		},
+	}
+	result, err := RunHeadless(option, "abc")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "> abc        [main]", result.Screen())
+	//    输入跟右侧提示符重叠时不显示
+	result, _ = RunHeadless(option, "abcdefghijk")
+	testStringEqual(t, "> abcdefghijk", result.Screen())
+	//    确定输入后不再显示
+	result, _ = RunHeadless(option, "abc<enter>")
+	testStringEqual(t, "> abc", result.Screen())
+}
+
+type _TestTransientPrompt struct {
+	BasePrompt
+}
+
+func (p *_TestTransientPrompt) GetPrompt() []token.Token {
+	return []token.Token{token.NewToken(token.Prompt, "~/code\n>>> ")}
+}
+
+func (p *_TestTransientPrompt) GetTransientPrompt() []token.Token {
+	return []token.Token{token.NewToken(token.Prompt, "$ ")}
+}
+
+func TestRunHeadless_TransientPrompt(t *testing.T) {
+	option := &CommandLineOption{
+		PromptFactory: func(code Code) Prompt {
+			return &_TestTransientPrompt{}
+		},
+	}
+	result, err := RunHeadless(option, "ls")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "~/code\n>>> ls", result.Screen())
+	result, _ = RunHeadless(option, "ls<enter>")
+	testStringEqual(t, "$ ls", result.Screen())
+}
+
+func TestRunHeadless_Toolbar(t *testing.T) {
+	option := &CommandLineOption{
+		Toolbar: func() []token.Token {
+			return []token.Token{token.NewToken(token.Toolbar, "[F1] help")}
+		},
+		SizeFunc: func() (int, int) {
+			return 20, 5
+		},
+	}
+	result, err := RunHeadless(option, "abc")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "> abc\n[F1] help", result.Screen())
+	//    确定输入后不再显示
+	result, _ = RunHeadless(option, "abc<enter>")
+	testStringEqual(t, "> abc", result.Screen())
+}
+
+// _TestSlowCompleteCode 在后台协程发送补全
+type _TestSlowCompleteCode struct {
+	_BaseCode
+}
+
+func (c *_TestSlowCompleteCode) GetCompletionsAsync(ctx context.Context) <-chan *Completion {
+	ch := make(chan *Completion)
+	go func() {
+		defer close(ch)
+		for _, completion := range []*Completion{
+			{Display: "apple", Suffix: "pple"},
+			{Display: "avocado", Suffix: "vocado"},
+		} {
+			select {
+			case ch <- completion:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func TestRunHeadless_AsyncComplete(t *testing.T) {
+	option := &CommandLineOption{
+		CodeFactory: func(document *Document) Code {
+			return &_TestSlowCompleteCode{_BaseCode{document: document}}
+		},
+	}
+	//    没有事件循环时同步等待补全
+	result, err := RunHeadless(option, "a<tab>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "apple", result.Text)
+}
+
+func TestRunHeadless_UnknownKey(t *testing.T) {
+	_, err := RunHeadless(nil, "abc<not_a_key>")
+	if err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+}
+
+func TestRunHeadless_MultiColumnCompletion(t *testing.T) {
+	option := &CommandLineOption{
+		CodeFactory:          WithCompleter(nil, NewWordCompleter([]string{"a1", "a2", "a3", "a4", "a5"})),
+		CompletionDisplay:    CompletionDisplayMultiColumn,
+		CompletionMenuHeight: 2,
+		SizeFunc: func() (int, int) {
+			return 40, 6
+		},
+	}
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"a<tab>", "a1"},
+		{"a<tab><arrow_right>", "a3"},
+		{"a<tab><arrow_right><arrow_right>", "a5"},
+		//    已经在最后一列
+		{"a<tab><arrow_right><arrow_right><arrow_right>", "a5"},
+		{"a<tab><arrow_right><arrow_right><arrow_left>", "a3"},
+		{"a<tab><arrow_down><arrow_right>", "a4"},
+	}
+	for _, tt := range tests {
+		result, err := RunHeadless(option, tt.script)
+		if err != nil {
+			t.Fatalf("run error: %v", err)
+		}
+		testStringEqual(t, tt.want, result.Text)
+	}
+	result, _ := RunHeadless(option, "a<tab><arrow_right>")
+	testStringEqual(t, "> a3\n   a1  a3  a5\n   a2  a4", result.Screen())
+}
+
+func TestRunHeadless_ReadlineCompletion(t *testing.T) {
+	newOption := func(words ...string) *CommandLineOption {
+		return &CommandLineOption{
+			CodeFactory:          WithCompleter(nil, NewWordCompleter(words)),
+			CompletionDisplay:    CompletionDisplayReadline,
+			CompletionQueryItems: 3,
+			SizeFunc: func() (int, int) {
+				return 40, 6
+			},
+		}
+	}
+	//    有共同前缀时补全前缀
+	result, err := RunHeadless(newOption("apple", "application"), "a<tab>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "appl", result.Text)
+	testStringEqual(t, "> appl", result.Screen())
+
+	//    没有共同前缀时在输入下方列出
+	result, _ = RunHeadless(newOption("apple", "apricot", "avocado"), "a<tab>")
+	testStringEqual(t, "a", result.Text)
+	testStringEqual(t, "> a\napple   apricot avocado\n> a", result.Screen())
+
+	//    补全太多时先询问
+	option := newOption("a1", "a2", "a3", "a4")
+	result, _ = RunHeadless(option, "a<tab>")
+	testStringEqual(t, "> a\nDisplay all 4 possibilities? (y/n)", result.Screen())
+	result, _ = RunHeadless(option, "a<tab>n")
+	testStringEqual(t, "a", result.Text)
+	testStringEqual(t, "> a", result.Screen())
+	result, _ = RunHeadless(option, "a<tab>y")
+	testStringEqual(t, "a", result.Text)
+	testStringEqual(t, "> a\na1 a2 a3 a4\n> a", result.Screen())
+	//    Ctrl-C 不列出，并且中断输入
+	option.OnAbort = AbortActionReturnError
+	result, _ = RunHeadless(option, "a<tab><ctrl_c>")
+	if result.Err != AbortError {
+		t.Errorf("want=%v, but got=%v", AbortError, result.Err)
+	}
+	testStringEqual(t, "> a", result.Screen())
+
+	//    vi 模式下同样用按键回答， Esc 只是不列出，不会回到 normal 模式
+	option.Handler = NewViHandler()
+	result, _ = RunHeadless(option, "a<tab><esc>x")
+	testStringEqual(t, "ax", result.Text)
+	result, _ = RunHeadless(option, "a<tab>y")
+	testStringEqual(t, "[I] > a\na1 a2 a3 a4\n[I] > a", result.Screen())
+
+	//    列出补全时输入还没有确定，保留完整的提示符
+	option = newOption("apple", "apricot", "avocado")
+	option.PromptFactory = func(code Code) Prompt {
+		return &_TestTransientPrompt{}
+	}
+	result, _ = RunHeadless(option, "a<tab>")
+	testStringEqual(t, "~/code\n>>> a\napple   apricot avocado\n~/code\n>>> a", result.Screen())
+}
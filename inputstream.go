@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/yetsing/startprompt/terminalcode"
 )
 
 // 解析 VT100 输入流数据
@@ -22,10 +24,19 @@ type InputStream struct {
 	isEmitEvent bool
 	//    是否缓冲事件
 	isBufferEvent bool
+	//    按键分发，处理组合键
+	keyDispatcher cKeyDispatcher
+	//    是否正在接收粘贴的文本（ bracketed paste ）
+	pasting     bool
+	pasteBuffer []rune
 }
 
 func (is *InputStream) Reset() {
 	is.isEmitEvent = false
+	is.keyDispatcher.reset()
+	//    粘贴的结束标记可能一直没有到来，丢弃未完成的粘贴
+	is.pasting = false
+	is.pasteBuffer = nil
 }
 
 // FeedTimeout 超时通知，主要用来快速触发 Esc 事件
@@ -34,8 +45,10 @@ func (is *InputStream) Reset() {
 //	因为 ANSI 转义序列都是 Esc 开头
 //	导致无法区分 Esc 和其他的快捷键，只能等待后续字符，再做判断
 //	因此按下 Esc 后不会有事件触发，现在通过超时来快速识别 Esc 键
+//
+//	组合键（比如 Ctrl-X Ctrl-U）等待后续按键超时，已经按下的按键按照单个按键处理
 func (is *InputStream) FeedTimeout() bool {
-	var offset int
+	offset := len(is.previous)
 	is.isEmitEvent = false
 	for i, r := range is.previous {
 		// 触发 Esc 事件
@@ -47,6 +60,9 @@ func (is *InputStream) FeedTimeout() bool {
 		}
 	}
 	is.previous = is.previous[offset:]
+	if is.cli != nil && is.keyDispatcher.timeout(is.cli.option.KeyBindings, is.handler, is.cli.option.ChordTimeout) {
+		is.isEmitEvent = true
+	}
 	return is.isEmitEvent
 }
 
@@ -70,11 +86,24 @@ func (is *InputStream) FeedRunes(runes []rune) {
 func (is *InputStream) Feed(r rune) {
 	var buffer []rune
 	is.isEmitEvent = false
+	if is.pasting {
+		is.feedPaste(r)
+		return
+	}
 	for {
 		key := string(r)
 		if len(is.previous) > 0 {
 			key = is.previous + key
 		}
+		// 粘贴开始，后续的字符都是粘贴的文本，直到粘贴结束
+		if key == terminalcode.BracketedPasteStart {
+			is.pasting = true
+			is.previous = ""
+			for _, r := range buffer {
+				is.feedPaste(r)
+			}
+			break
+		}
 		// 检查是不是快捷键操作
 		action, found := keyActions[key]
 		if found {
@@ -82,6 +111,12 @@ func (is *InputStream) Feed(r rune) {
 			is.previous = ""
 			break
 		}
+		// 检查是不是跟修饰键一起按下的方向键等按键
+		if modifiedKey, found := modifiedKeyActions[key]; found {
+			is.callModifiedHandler(modifiedKey.eventType, modifiedKey.modifiers, []rune(key)...)
+			is.previous = ""
+			break
+		}
 		// 检查是不是多字符快捷键操作
 		// 因为多字符需要输入多次，所以查看有没有哪个 key 的前缀可以匹配上
 		if prefixMatchKeyActions(key) {
@@ -118,14 +153,52 @@ func (is *InputStream) Feed(r rune) {
 	}
 }
 
+// feedPaste 接收粘贴的文本，遇到粘贴结束标记时触发粘贴事件
+func (is *InputStream) feedPaste(r rune) {
+	if !is.pasting {
+		is.Feed(r)
+		return
+	}
+	is.pasteBuffer = append(is.pasteBuffer, r)
+	end := []rune(terminalcode.BracketedPasteEnd)
+	n := len(is.pasteBuffer) - len(end)
+	if n < 0 || string(is.pasteBuffer[n:]) != terminalcode.BracketedPasteEnd {
+		return
+	}
+	text := normalizeNewline(string(is.pasteBuffer[:n]))
+	is.pasting = false
+	is.pasteBuffer = nil
+	is.callHandler(EventTypePaste, []rune(text)...)
+}
+
+// normalizeNewline 将 \r\n 和 \r 换行统一成 \n
+func normalizeNewline(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
 func (is *InputStream) callHandler(eventType EventType, a ...rune) {
+	is.callModifiedHandler(eventType, 0, a...)
+}
+
+func (is *InputStream) callModifiedHandler(eventType EventType, modifiers KeyModifier, a ...rune) {
 	is.isEmitEvent = true
 	event := NewEventKey(eventType, a, is.cli, nil)
+	event.modifiers = modifiers
 	if is.isBufferEvent {
 		is.eventBuffer.append(event)
 	} else {
-		is.handler.Handle(event)
+		is.dispatch(event)
+	}
+}
+
+// dispatch 事件绑定了命令时执行命令，否则交给事件处理器
+func (is *InputStream) dispatch(event Event) {
+	var bindings *KeyBindings
+	if is.cli != nil {
+		bindings = is.cli.option.KeyBindings
 	}
+	is.keyDispatcher.dispatch(bindings, is.handler, event)
 }
 
 func (is *InputStream) openEventBuffer() {
@@ -138,7 +211,7 @@ func (is *InputStream) closeEventBuffer() {
 
 func (is *InputStream) flushEventBuffer() {
 	for _, event := range is.eventBuffer.getAll() {
-		is.handler.Handle(event)
+		is.dispatch(event)
 	}
 	is.eventBuffer.reset()
 }
@@ -154,14 +227,41 @@ func runeAt(s string, index int) rune {
 
 // 检查 prefix 是否是 keyActions 中某个 key 的前缀
 func prefixMatchKeyActions(prefix string) bool {
+	if strings.HasPrefix(terminalcode.BracketedPasteStart, prefix) {
+		return true
+	}
 	for key := range keyActions {
 		if strings.HasPrefix(key, prefix) {
 			return true
 		}
 	}
+	for key := range modifiedKeyActions {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
 	return false
 }
 
+// keySequence 返回事件对应的按键序列，有多个序列时返回最短的（长度相同时返回字典序最小的）
+func keySequence(eventType EventType) (string, bool) {
+	if eventType == EventTypeEscape {
+		return "\x1b", true
+	}
+	var result string
+	found := false
+	for key, action := range keyActions {
+		if action != eventType {
+			continue
+		}
+		if !found || len(key) < len(result) || (len(key) == len(result) && key < result) {
+			result = key
+			found = true
+		}
+	}
+	return result, found
+}
+
 var keyActions = map[string]EventType{
 	// Control-Space (Also for Ctrl-@)
 	"\x00": EventTypeCtrlSpace,
@@ -251,4 +351,63 @@ var keyActions = map[string]EventType{
 	"\x1b[32~": EventTypeF18,
 	"\x1b[33~": EventTypeF19,
 	"\x1b[34~": EventTypeF20,
+
+	// Meta (Alt) 键会在按键前面加上 Esc
+	"\x1bb":    EventTypeMetaB,
+	"\x1bd":    EventTypeMetaD,
+	"\x1bf":    EventTypeMetaF,
+	"\x1bw":    EventTypeMetaW,
+	"\x1by":    EventTypeMetaY,
+	"\x1b\x7f": EventTypeMetaBackspace,
+	"\x1b\x08": EventTypeMetaBackspace,
+}
+
+type modifiedKey struct {
+	eventType EventType
+	modifiers KeyModifier
+}
+
+// modifiedKeyActions 跟修饰键一起按下的方向键等按键
+//
+//	xterm 会在按键序列中加上修饰键参数，参数等于 1 + 修饰键（Shift 1 ，Alt 2 ，Ctrl 4）
+//	比如 Ctrl-Right 是 \x1b[1;5C ， Shift-Up 是 \x1b[1;2A ， Ctrl-Delete 是 \x1b[3;5~
+var modifiedKeyActions = func() map[string]modifiedKey {
+	actions := make(map[string]modifiedKey)
+	finals := map[byte]EventType{
+		'A': EventTypeArrowUp,
+		'B': EventTypeArrowDown,
+		'C': EventTypeArrowRight,
+		'D': EventTypeArrowLeft,
+		'H': EventTypeHome,
+		'F': EventTypeEnd,
+	}
+	numbers := map[int]EventType{
+		3: EventTypeDeleteAction,
+		5: EventTypePageUp,
+		6: EventTypePageDown,
+	}
+	for modifiers := KeyModShift; modifiers <= KeyModShift|KeyModAlt|KeyModCtrl; modifiers++ {
+		for final, eventType := range finals {
+			key := fmt.Sprintf("\x1b[1;%d%c", 1+modifiers, final)
+			actions[key] = modifiedKey{eventType, modifiers}
+		}
+		for number, eventType := range numbers {
+			key := fmt.Sprintf("\x1b[%d;%d~", number, 1+modifiers)
+			//    keyActions 中已经有的按键（比如 Shift-Delete）保持不变
+			if _, found := keyActions[key]; !found {
+				actions[key] = modifiedKey{eventType, modifiers}
+			}
+		}
+	}
+	return actions
+}()
+
+// modifiedKeySequence 返回跟修饰键一起按下的按键对应的按键序列
+func modifiedKeySequence(eventType EventType, modifiers KeyModifier) (string, bool) {
+	for key, action := range modifiedKeyActions {
+		if action.eventType == eventType && action.modifiers == modifiers {
+			return key, true
+		}
+	}
+	return "", false
 }
@@ -5,8 +5,9 @@ import (
 )
 
 type tKey struct {
-	event EventType
-	data  string
+	event     EventType
+	data      string
+	modifiers KeyModifier
 }
 
 type testHandler struct {
@@ -21,10 +22,12 @@ func newTestHandler() *testHandler {
 	}
 }
 
-func (h *testHandler) Handle(event EventType, a ...rune) {
+func (h *testHandler) Handle(event Event) {
+	ek := event.(*EventKey)
 	k := tKey{
-		event: event,
-		data:  string(a),
+		event:     ek.Type(),
+		data:      string(ek.GetData()),
+		modifiers: ek.Modifiers(),
 	}
 	h.keys = append(h.keys, k)
 }
@@ -71,6 +74,21 @@ func TestInputStreamEscape(t *testing.T) {
 	testStringEqual(t, "h", handler.keys[1].data)
 }
 
+func TestInputStreamFeedTimeout(t *testing.T) {
+	handler := newTestHandler()
+	stream := NewInputStream(handler, nil)
+	stream.FeedData("\x1b")
+	testIntEqual(t, 0, len(handler.keys))
+
+	testBoolEqual(t, true, stream.FeedTimeout())
+	testIntEqual(t, 1, len(handler.keys))
+	testKeyEventEqual(t, EventTypeEscape, handler.keys[0].event)
+
+	//    已经触发的 Esc 不会再次触发
+	testBoolEqual(t, false, stream.FeedTimeout())
+	testIntEqual(t, 1, len(handler.keys))
+}
+
 func TestInputStreamMetaArrows(t *testing.T) {
 	handler := newTestHandler()
 	stream := NewInputStream(handler, nil)
@@ -81,6 +99,70 @@ func TestInputStreamMetaArrows(t *testing.T) {
 	testKeyEventEqual(t, EventTypeArrowLeft, handler.keys[1].event)
 }
 
+func TestInputStreamMetaKeys(t *testing.T) {
+	handler := newTestHandler()
+	stream := NewInputStream(handler, nil)
+	stream.FeedData("\x1bb\x1bf\x1bd\x1b\x7f\x1bx")
+
+	testIntEqual(t, 6, len(handler.keys))
+	testKeyEventEqual(t, EventTypeMetaB, handler.keys[0].event)
+	testKeyEventEqual(t, EventTypeMetaF, handler.keys[1].event)
+	testKeyEventEqual(t, EventTypeMetaD, handler.keys[2].event)
+	testKeyEventEqual(t, EventTypeMetaBackspace, handler.keys[3].event)
+	testKeyEventEqual(t, EventTypeEscape, handler.keys[4].event)
+	testKeyEventEqual(t, EventTypeInsertChar, handler.keys[5].event)
+	testStringEqual(t, "\x1bb", handler.keys[0].data)
+	testStringEqual(t, "x", handler.keys[5].data)
+}
+
+func TestInputStreamModifiedArrows(t *testing.T) {
+	handler := newTestHandler()
+	stream := NewInputStream(handler, nil)
+	stream.FeedData("\x1b[1;5C\x1b[1;2A\x1b[1;6H\x1b[3;5~\x1b[3;2~\x1b[D")
+
+	testIntEqual(t, 6, len(handler.keys))
+	want := []tKey{
+		{EventTypeArrowRight, "\x1b[1;5C", KeyModCtrl},
+		{EventTypeArrowUp, "\x1b[1;2A", KeyModShift},
+		{EventTypeHome, "\x1b[1;6H", KeyModCtrl | KeyModShift},
+		{EventTypeDeleteAction, "\x1b[3;5~", KeyModCtrl},
+		{EventTypeShiftDelete, "\x1b[3;2~", 0},
+		{EventTypeArrowLeft, "\x1b[D", 0},
+	}
+	for i, key := range want {
+		if handler.keys[i] != key {
+			t.Errorf("key %d want=%+v, but got=%+v", i, key, handler.keys[i])
+		}
+	}
+}
+
+func TestInputStreamBracketedPaste(t *testing.T) {
+	handler := newTestHandler()
+	stream := NewInputStream(handler, nil)
+	stream.FeedData("a\x1b[200~x\r  \x1b[Ay\r\n\x1b[201~b")
+
+	testIntEqual(t, 3, len(handler.keys))
+	testKeyEventEqual(t, EventTypeInsertChar, handler.keys[0].event)
+	testKeyEventEqual(t, EventTypePaste, handler.keys[1].event)
+	testKeyEventEqual(t, EventTypeInsertChar, handler.keys[2].event)
+	testStringEqual(t, "x\n  \x1b[Ay\n", handler.keys[1].data)
+	testStringEqual(t, "b", handler.keys[2].data)
+}
+
+func TestInputStreamBracketedPasteReset(t *testing.T) {
+	handler := newTestHandler()
+	stream := NewInputStream(handler, nil)
+	//    只有开始标记，没有结束标记
+	stream.FeedData("\x1b[200~xy")
+	testIntEqual(t, 0, len(handler.keys))
+
+	stream.Reset()
+	stream.FeedData("a")
+	testIntEqual(t, 1, len(handler.keys))
+	testKeyEventEqual(t, EventTypeInsertChar, handler.keys[0].event)
+	testStringEqual(t, "a", handler.keys[0].data)
+}
+
 func TestInputStreamControlSquareClose(t *testing.T) {
 	handler := newTestHandler()
 	stream := NewInputStream(handler, nil)
@@ -105,3 +187,23 @@ func TestInputStreamInvalid(t *testing.T) {
 	testStringEqual(t, "*", handler.keys[2].data)
 
 }
+
+func TestEventTypeString(t *testing.T) {
+	//    新增的事件追加在最后，原有事件的值保持不变
+	testIntEqual(t, int(EventTypeInsertChar)+1, int(EventTypeMouseWheelUp))
+	tests := []struct {
+		event EventType
+		str   string
+	}{
+		{EventTypeInsertChar, "<insert_char>"},
+		{EventTypeMouseTripleClick, "<mouse_triple_click>"},
+		{EventTypeMetaB, "<meta_b>"},
+		{EventTypeMetaBackspace, "<meta_backspace>"},
+		{EventTypeMetaW, "<meta_w>"},
+		{EventTypeMetaY, "<meta_y>"},
+		{EventTypePaste, "<paste>"},
+	}
+	for _, tt := range tests {
+		testStringEqual(t, tt.str, tt.event.String())
+	}
+}
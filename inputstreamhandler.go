@@ -28,13 +28,31 @@ func (b *BaseHandler) Handle(event Event) {
 	eventType := ek.Type()
 	b.cli = ek.GetCommandLine()
 	b.line = b.cli.GetLine()
-	b.lastEvent = eventType
+
+	//    询问是否列出全部补全时，按键用来回答
+	if b.line.mode.Is(linemode.CompletionQuery) && answerCompletionQuery(b.line, eventType, ek.GetData()) {
+		return
+	}
+
+	//    增量搜索时，除了搜索相关的按键，其他按键都会先结束搜索，再执行原本的操作
+	if b.line.mode.Is(linemode.IncrementalSearch) && !isIncrementalSearchEvent(eventType) {
+		b.line.AcceptSearch()
+	}
 
 	if b.needsToSave(eventType) {
 		b.line.SaveToUndoStack()
 	}
+	b.lastEvent = eventType
 
 	data := ek.GetData()
+	//    跟修饰键一起按下的按键，没有对应的操作时按照单独的按键处理
+	//    除了会替换或者删除选中文本的按键，其他按键都会取消选中，按住 Shift 移动光标时扩展选中
+	if !ek.Modifiers().Has(KeyModShift) && !isSelectionEvent(eventType) {
+		b.line.ClearSelection()
+	}
+	if ek.Modifiers() != 0 && b.handleModifiedKey(eventType, ek.Modifiers()) {
+		return
+	}
 	switch eventType {
 	case EventTypeCtrlSpace:
 		b.CtrlSpace(data)
@@ -164,6 +182,20 @@ func (b *BaseHandler) Handle(event Event) {
 		b.EscapeAction(data)
 	case EventTypeInsertChar:
 		b.InsertChar(data)
+	case EventTypeMetaB:
+		b.MetaB(data)
+	case EventTypeMetaD:
+		b.MetaD(data)
+	case EventTypeMetaF:
+		b.MetaF(data)
+	case EventTypeMetaW:
+		b.MetaW(data)
+	case EventTypeMetaY:
+		b.MetaY(data)
+	case EventTypeMetaBackspace:
+		b.MetaBackspace(data)
+	case EventTypePaste:
+		b.Paste(data)
 	}
 }
 
@@ -200,10 +232,18 @@ func (b *BaseHandler) CtrlF(_ []rune) {
 	b.line.CursorRight()
 }
 func (b *BaseHandler) CtrlG(_ []rune) {
-
+	b.line.CancelSearch()
 }
 func (b *BaseHandler) CtrlH(_ []rune) {
+	if b.line.mode.Is(linemode.IncrementalSearch) {
+		b.line.DeleteSearchCharacter()
+		return
+	}
 	b.line.ToNormalMode()
+	if b.line.HasSelection() {
+		b.line.DeleteSelection()
+		return
+	}
 	b.line.DeleteCharacterBeforeCursor(1)
 }
 func (b *BaseHandler) CtrlI(_ []rune) {
@@ -216,7 +256,7 @@ func (b *BaseHandler) CtrlJ(_ []rune) {
 }
 func (b *BaseHandler) CtrlK(_ []rune) {
 	b.line.ToNormalMode()
-	b.line.DeleteUntilEndOfLine()
+	b.line.KillUntilEndOfLine()
 }
 func (b *BaseHandler) CtrlL(_ []rune) {
 	b.cli.GetRenderer().Clear()
@@ -235,27 +275,53 @@ func (b *BaseHandler) CtrlP(_ []rune) {
 	b.line.AutoUp()
 }
 func (b *BaseHandler) CtrlQ(_ []rune) {}
-func (b *BaseHandler) CtrlR(_ []rune) {}
-func (b *BaseHandler) CtrlS(_ []rune) {}
+func (b *BaseHandler) CtrlR(_ []rune) {
+	b.line.ReverseSearch()
+}
+func (b *BaseHandler) CtrlS(_ []rune) {
+	b.line.ForwardSearch()
+}
 func (b *BaseHandler) CtrlT(_ []rune) {}
 func (b *BaseHandler) CtrlU(_ []rune) {
 	b.line.ToNormalMode()
-	b.line.DeleteFromStartOfLine()
+	b.line.KillFromStartOfLine()
+}
+func (b *BaseHandler) CtrlV(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.PasteClipboard()
 }
-func (b *BaseHandler) CtrlV(_ []rune) {}
 func (b *BaseHandler) CtrlW(_ []rune) {
 	b.line.ToNormalMode()
-	b.line.DeleteWordBeforeCursor()
+	if b.line.HasSelection() {
+		b.line.CutSelection()
+		return
+	}
+	b.line.KillWordBeforeCursor()
+}
+func (b *BaseHandler) CtrlX(_ []rune) {}
+func (b *BaseHandler) CtrlY(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.Yank()
 }
-func (b *BaseHandler) CtrlX(_ []rune)           {}
-func (b *BaseHandler) CtrlY(_ []rune)           {}
 func (b *BaseHandler) CtrlZ(_ []rune)           {}
 func (b *BaseHandler) CtrlBackslash(_ []rune)   {}
 func (b *BaseHandler) CtrlSquareClose(_ []rune) {}
-func (b *BaseHandler) CtrlCircumflex(_ []rune)  {}
-func (b *BaseHandler) CtrlUnderscore(_ []rune)  {}
+func (b *BaseHandler) CtrlCircumflex(_ []rune) {
+	b.line.Redo()
+}
+func (b *BaseHandler) CtrlUnderscore(_ []rune) {
+	b.line.Undo()
+}
 func (b *BaseHandler) Backspace(_ []rune) {
+	if b.line.mode.Is(linemode.IncrementalSearch) {
+		b.line.DeleteSearchCharacter()
+		return
+	}
 	b.line.ToNormalMode()
+	if b.line.HasSelection() {
+		b.line.DeleteSelection()
+		return
+	}
 	b.line.DeleteCharacterBeforeCursor(1)
 }
 func (b *BaseHandler) ArrowUp(_ []rune) {
@@ -265,12 +331,10 @@ func (b *BaseHandler) ArrowDown(_ []rune) {
 	b.line.AutoDown()
 }
 func (b *BaseHandler) ArrowRight(_ []rune) {
-	b.line.ToNormalMode()
-	b.line.CursorRight()
+	b.line.AutoRight()
 }
 func (b *BaseHandler) ArrowLeft(_ []rune) {
-	b.line.ToNormalMode()
-	b.line.CursorLeft()
+	b.line.AutoLeft()
 }
 func (b *BaseHandler) Home(_ []rune) {
 	b.line.ToNormalMode()
@@ -282,6 +346,10 @@ func (b *BaseHandler) End(_ []rune) {
 }
 func (b *BaseHandler) DeleteAction(_ []rune) {
 	b.line.ToNormalMode()
+	if b.line.HasSelection() {
+		b.line.DeleteSelection()
+		return
+	}
 	b.line.DeleteCharacterAfterCursor(1)
 }
 func (b *BaseHandler) PageUp(_ []rune)   {}
@@ -310,7 +378,38 @@ func (b *BaseHandler) F20(_ []rune)      {}
 func (b *BaseHandler) EscapeAction(_ []rune) {
 	b.line.CancelComplete()
 }
+func (b *BaseHandler) MetaB(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.CursorWordBack()
+}
+func (b *BaseHandler) MetaD(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.KillWord()
+}
+func (b *BaseHandler) MetaF(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.CursorWordForward()
+}
+func (b *BaseHandler) MetaW(_ []rune) {
+	b.line.CopySelection()
+}
+func (b *BaseHandler) MetaY(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.YankPop()
+}
+func (b *BaseHandler) MetaBackspace(_ []rune) {
+	b.line.ToNormalMode()
+	b.line.KillWordBeforeCursor()
+}
+func (b *BaseHandler) Paste(data []rune) {
+	b.line.ToNormalMode()
+	b.line.PasteText(data)
+}
 func (b *BaseHandler) InsertChar(data []rune) {
+	if b.line.mode.Is(linemode.IncrementalSearch) {
+		b.line.InsertSearchText(data)
+		return
+	}
 	b.line.ToNormalMode()
 	b.line.InsertText(data, true)
 }
@@ -322,9 +421,30 @@ func (b *BaseHandler) enter() {
 	}
 }
 
+// handleModifiedKey 处理跟修饰键一起按下的按键，返回 false 表示没有对应的操作
+//
+//	按住 Shift 时光标移动会扩展选中区域
+func (b *BaseHandler) handleModifiedKey(eventType EventType, modifiers KeyModifier) bool {
+	move := modifiedKeyMotion(eventType, modifiers)
+	if move == nil {
+		return false
+	}
+	b.line.ToNormalMode()
+	if modifiers.Has(KeyModShift) {
+		b.line.ExtendSelection(move)
+	} else {
+		move(b.line)
+	}
+	return true
+}
+
 func (b *BaseHandler) needsToSave(event EventType) bool {
 	// 用户输入字符时不进行保存，用户输入字符后再进行保存
 	// 这样一次可以撤销用户多次输入，而不是撤销一个个字符
+	// 撤销和恢复操作本身也不进行保存
+	if event == EventTypeCtrlUnderscore || event == EventTypeCtrlCircumflex {
+		return false
+	}
 	return !(event == EventTypeInsertChar && b.lastEvent == EventTypeInsertChar)
 }
 
@@ -334,11 +454,8 @@ func (b *BaseHandler) tab() {
 		line.AcceptComplete()
 		return
 	}
-	if !line.Complete() {
-		line.CompleteNext(1)
-		// 如果没有补全，插入 4 个空格
-		if line.mode.Is(linemode.Normal) {
-			line.InsertText([]rune("    "), true)
-		}
+	// 如果没有补全，插入 4 个空格
+	if !line.Complete() && !line.startComplete(true) {
+		line.InsertText([]rune("    "), true)
 	}
 }
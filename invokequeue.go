@@ -0,0 +1,35 @@
+package startprompt
+
+import "sync"
+
+/*
+其他协程对当前输入的修改，放到队列里面，由事件循环取出执行，避免跟事件循环同时修改 Line
+*/
+
+type cInvokeQueue struct {
+	mutex sync.Mutex
+	funcs []func(line *Line)
+}
+
+func newInvokeQueue() *cInvokeQueue {
+	return &cInvokeQueue{}
+}
+
+// push 添加修改（ goroutine 安全）
+func (iq *cInvokeQueue) push(fn func(line *Line)) {
+	iq.mutex.Lock()
+	defer iq.mutex.Unlock()
+	iq.funcs = append(iq.funcs, fn)
+}
+
+// run 按照添加的顺序执行所有修改，返回 false 表示没有修改
+func (iq *cInvokeQueue) run(line *Line) bool {
+	iq.mutex.Lock()
+	funcs := iq.funcs
+	iq.funcs = nil
+	iq.mutex.Unlock()
+	for _, fn := range funcs {
+		fn(line)
+	}
+	return len(funcs) > 0
+}
@@ -0,0 +1,657 @@
+package startprompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yetsing/startprompt/enums/linemode"
+)
+
+/*
+按键绑定，将按键序列映射到命名的命令（比如 "forward-word" "kill-line"）
+绑定的按键优先于事件处理器，没有绑定的按键仍然交给事件处理器
+*/
+
+// Command 绑定到按键上的命令
+type Command func(ctx *CommandContext)
+
+// CommandContext 命令执行时的上下文， CommandLine 和 TCommandLine 通用
+type CommandContext struct {
+	// Line 当前的输入
+	Line *Line
+	// Data 按键对应的字符
+	Data []rune
+	cli  *CommandLine
+	tcli *TCommandLine
+}
+
+func newCommandContext(ek *EventKey) *CommandContext {
+	ctx := &CommandContext{Data: ek.GetData(), cli: ek.cli, tcli: ek.tcli}
+	if ek.cli != nil {
+		ctx.Line = ek.cli.GetLine()
+	} else {
+		ctx.Line = ek.GetTCommandLine().GetLine()
+	}
+	return ctx
+}
+
+// Accept 确定本次输入
+func (ctx *CommandContext) Accept() {
+	if ctx.cli != nil {
+		ctx.cli.SetAcceptFlag()
+	} else {
+		ctx.tcli.SetAccept()
+	}
+}
+
+// Abort 丢弃本次输入，跟 Ctrl-C 一样
+func (ctx *CommandContext) Abort() {
+	if ctx.cli != nil {
+		ctx.cli.SetAbortFlag()
+	} else {
+		ctx.tcli.SetAbort()
+	}
+}
+
+// Exit 退出，跟没有输入时按 Ctrl-D 一样
+func (ctx *CommandContext) Exit() {
+	if ctx.cli != nil {
+		ctx.cli.SetExitFlag()
+	} else {
+		ctx.tcli.SetExit()
+	}
+}
+
+// ClearScreen 清空屏幕，将输入置顶
+func (ctx *CommandContext) ClearScreen() {
+	if ctx.cli != nil {
+		ctx.cli.GetRenderer().Clear()
+	} else {
+		ctx.tcli.GetRenderer().Clear()
+	}
+}
+
+// KeyBindings 按键绑定注册表（ goroutine 安全），可以在运行时修改
+type KeyBindings struct {
+	mutex    sync.RWMutex
+	commands map[string]Command
+	//    按键序列（比如 "<ctrl_x><ctrl_u>"）到命令名字的映射
+	bindings map[string]string
+}
+
+// NewKeyBindings 新建按键绑定，包含所有内置命令，但是没有绑定任何按键
+func NewKeyBindings() *KeyBindings {
+	kb := &KeyBindings{
+		commands: make(map[string]Command, len(builtinCommands)),
+		bindings: make(map[string]string),
+	}
+	for name, command := range builtinCommands {
+		kb.commands[name] = command
+	}
+	return kb
+}
+
+// RegisterCommand 注册命令，已有同名命令时覆盖
+func (kb *KeyBindings) RegisterCommand(name string, command Command) {
+	kb.mutex.Lock()
+	defer kb.mutex.Unlock()
+	kb.commands[name] = command
+}
+
+// Commands 返回所有命令的名字（已排序）
+func (kb *KeyBindings) Commands() []string {
+	kb.mutex.RLock()
+	defer kb.mutex.RUnlock()
+	names := make([]string, 0, len(kb.commands))
+	for name := range kb.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bind 将按键序列绑定到命令，已有绑定时覆盖
+//
+//	多个按键表示组合键，比如 Bind("undo", EventTypeCtrlX, EventTypeCtrlU) 表示先按 Ctrl-X 再按 Ctrl-U
+func (kb *KeyBindings) Bind(command string, keys ...EventType) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("bind %s: empty key sequence", command)
+	}
+	for _, key := range keys {
+		if !isBindableEvent(key) {
+			return fmt.Errorf("bind %s: key %s can not be bound", command, key)
+		}
+	}
+	return kb.bind(command, keySequenceName(keys))
+}
+
+// BindSequence 将按键序列绑定到命令，按键序列的格式跟 Load 一致
+//
+//	除了第一个按键，后面的按键可以是普通字符，比如 "<escape>d" 表示先按 Esc 再按 d
+//	字符 < 本身用 <lt> 表示，比如 "<ctrl_x><lt>"
+func (kb *KeyBindings) BindSequence(command string, sequence string) error {
+	keys, err := parseKeySequence(sequence)
+	if err != nil {
+		return fmt.Errorf("bind %s: %w", command, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("bind %s: empty key sequence", command)
+	}
+	return kb.bind(command, strings.Join(keys, ""))
+}
+
+func (kb *KeyBindings) bind(command string, sequence string) error {
+	kb.mutex.Lock()
+	defer kb.mutex.Unlock()
+	if _, found := kb.commands[command]; !found {
+		return fmt.Errorf("bind %s: unknown command", command)
+	}
+	kb.bindings[sequence] = command
+	return nil
+}
+
+// Unbind 删除按键序列的绑定，按键恢复成事件处理器的默认行为
+func (kb *KeyBindings) Unbind(keys ...EventType) {
+	kb.mutex.Lock()
+	defer kb.mutex.Unlock()
+	delete(kb.bindings, keySequenceName(keys))
+}
+
+// Binding 返回按键序列绑定的命令名字
+func (kb *KeyBindings) Binding(keys ...EventType) (string, bool) {
+	kb.mutex.RLock()
+	defer kb.mutex.RUnlock()
+	command, found := kb.bindings[keySequenceName(keys)]
+	return command, found
+}
+
+// match 返回按键序列绑定的命令， isPrefix 表示按键序列是否是某个更长绑定的前缀
+func (kb *KeyBindings) match(keys []string) (name string, command Command, found bool, isPrefix bool) {
+	kb.mutex.RLock()
+	defer kb.mutex.RUnlock()
+	sequence := strings.Join(keys, "")
+	name, found = kb.bindings[sequence]
+	if found {
+		command = kb.commands[name]
+	}
+	for key := range kb.bindings {
+		if len(key) > len(sequence) && strings.HasPrefix(key, sequence) {
+			isPrefix = true
+			break
+		}
+	}
+	return name, command, found, isPrefix
+}
+
+// Load 从 reader 中读取按键绑定
+//
+//	每行一个绑定，按键序列和命令名字之间用空白分隔，按键名字跟 EventType.String() 一致
+//	空行和 # 开头的行会被忽略，比如：
+//
+//	# 移动到下一个单词
+//	<ctrl_o> forward-word
+//	<F2> undo
+func (kb *KeyBindings) Load(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: want \"<keys> <command>\", but got %q", lineno, text)
+		}
+		if err := kb.BindSequence(fields[1], fields[0]); err != nil {
+			return fmt.Errorf("line %d: %w", lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadFile 从文件中读取按键绑定，格式见 Load
+func (kb *KeyBindings) LoadFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return kb.Load(file)
+}
+
+// parseKeySequence 解析按键序列，比如 "<ctrl_x><ctrl_u>" "<escape>d" "<ctrl_arrow_left>" ，返回每个按键的名字
+func parseKeySequence(s string) ([]string, error) {
+	var keys []string
+	for len(s) > 0 {
+		if s[0] != '<' {
+			r, size := utf8.DecodeRuneInString(s)
+			if len(keys) == 0 || r == '>' || unicode.IsSpace(r) || unicode.IsControl(r) {
+				return nil, fmt.Errorf("invalid key sequence %q", s)
+			}
+			keys = append(keys, string(r))
+			s = s[size:]
+			continue
+		}
+		end := strings.IndexByte(s, '>')
+		if end == -1 {
+			return nil, fmt.Errorf("invalid key sequence %q", s)
+		}
+		//    字符 < ，名字跟 eventKeyNames 一致
+		if s[:end+1] == "<lt>" {
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("invalid key sequence %q", s)
+			}
+			keys = append(keys, "<lt>")
+			s = s[end+1:]
+			continue
+		}
+		key, modifiers, found := parseKeyName(s[:end+1])
+		if !found {
+			return nil, fmt.Errorf("unknown key %s", s[:end+1])
+		}
+		if !isBindableEvent(key) {
+			return nil, fmt.Errorf("key %s can not be bound", key)
+		}
+		keys = append(keys, keyName(key, modifiers))
+		s = s[end+1:]
+	}
+	return keys, nil
+}
+
+func keySequenceName(keys []EventType) string {
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key.String())
+	}
+	return sb.String()
+}
+
+// isBindableEvent 返回 true 表示事件可以绑定命令，字符输入、粘贴和鼠标事件不能绑定
+func isBindableEvent(eventType EventType) bool {
+	if eventType < 0 || int(eventType) >= len(eventTypeStr) {
+		return false
+	}
+	isMouseEvent := eventType >= EventTypeMouseWheelUp && eventType <= EventTypeMouseTripleClick
+	return eventType != EventTypeInsertChar && eventType != EventTypePaste && !isMouseEvent
+}
+
+// defaultChordTimeout 组合键两次按键之间默认的最长等待时间
+const defaultChordTimeout = time.Second
+
+// cKeyDispatcher 按键分发，事件绑定了命令时执行命令，否则交给事件处理器
+//
+//	按下的按键是某个组合键（比如 Ctrl-X Ctrl-U）的前缀时，先暂存起来等待后续按键
+//	后续按键匹配不上或者等待超时，暂存的按键按照单个按键处理
+type cKeyDispatcher struct {
+	//    暂存的组合键前缀
+	pending []*EventKey
+	//    最后一次暂存按键的时间
+	pendingTime time.Time
+}
+
+func (d *cKeyDispatcher) dispatch(bindings *KeyBindings, handler EventHandler, event Event) {
+	ek, ok := event.(*EventKey)
+	if !ok || bindings == nil {
+		d.flush(bindings, handler)
+		handler.Handle(event)
+		return
+	}
+	if len(d.pending) == 0 && !isBindableEvent(ek.Type()) {
+		handler.Handle(event)
+		return
+	}
+	//    多个字符输入可能被合并成一个事件，组合键需要逐个字符匹配
+	if ek.Type() == EventTypeInsertChar && len(ek.data) > 1 {
+		d.dispatch(bindings, handler, NewEventKey(EventTypeInsertChar, ek.data[:1], ek.cli, ek.tcli))
+		d.dispatch(bindings, handler, NewEventKey(EventTypeInsertChar, ek.data[1:], ek.cli, ek.tcli))
+		return
+	}
+
+	keys := append(d.pending[:len(d.pending):len(d.pending)], ek)
+	name, command, found, isPrefix := bindings.match(eventKeyNames(keys))
+	//    快速按下 Esc 和字符会被识别成 Meta 组合键，没有绑定 Meta 组合键时尝试按照 Esc 组合键处理
+	if !found && !isPrefix {
+		if keyType, keyData, ok := metaKeyOf(ek.Type()); ok {
+			escapeEvent := NewEventKey(EventTypeEscape, []rune{'\x1b'}, ek.cli, ek.tcli)
+			keyEvent := NewEventKey(keyType, keyData, ek.cli, ek.tcli)
+			_, _, escapeFound, escapePrefix := bindings.match(eventKeyNames(append(keys[:len(keys)-1], escapeEvent, keyEvent)))
+			if escapeFound || escapePrefix {
+				d.dispatch(bindings, handler, escapeEvent)
+				d.dispatch(bindings, handler, keyEvent)
+				return
+			}
+		}
+	}
+	switch {
+	case isPrefix:
+		d.setPending(keys)
+	case found:
+		d.setPending(nil)
+		DebugLog("run command=%s for keys=%s", name, eventKeyNames(keys))
+		runCommand(name, command, newCommandContext(ek))
+	case len(d.pending) == 0:
+		handler.Handle(event)
+	default:
+		//    组合键匹配失败，先处理暂存的按键，再重新处理当前按键
+		d.flush(bindings, handler)
+		d.dispatch(bindings, handler, event)
+	}
+}
+
+// flush 处理暂存的按键，返回值表示是否有暂存的按键
+//
+//	暂存按键中最长的有绑定的前缀执行对应的命令，没有的话第一个按键交给事件处理器，剩下的按键重新分发
+func (d *cKeyDispatcher) flush(bindings *KeyBindings, handler EventHandler) bool {
+	pending := d.pending
+	if len(pending) == 0 {
+		return false
+	}
+	d.setPending(nil)
+	if bindings == nil {
+		for _, ek := range pending {
+			handler.Handle(ek)
+		}
+		return true
+	}
+	n := 0
+	for i := len(pending); i > 0; i-- {
+		if name, command, found, _ := bindings.match(eventKeyNames(pending[:i])); found {
+			DebugLog("run command=%s for keys=%s", name, eventKeyNames(pending[:i]))
+			runCommand(name, command, newCommandContext(pending[i-1]))
+			n = i
+			break
+		}
+	}
+	if n == 0 {
+		handler.Handle(pending[0])
+		n = 1
+	}
+	for _, ek := range pending[n:] {
+		d.dispatch(bindings, handler, ek)
+	}
+	return true
+}
+
+// timeout 暂存的按键等待超时后按照单个按键处理，返回值表示是否有按键被处理
+func (d *cKeyDispatcher) timeout(bindings *KeyBindings, handler EventHandler, timeout time.Duration) bool {
+	if len(d.pending) == 0 || time.Since(d.pendingTime) < timeout {
+		return false
+	}
+	return d.flush(bindings, handler)
+}
+
+// timeoutChannel 返回暂存按键超时时触发的 channel ，没有暂存按键时返回 nil
+func (d *cKeyDispatcher) timeoutChannel(timeout time.Duration) <-chan time.Time {
+	if len(d.pending) == 0 {
+		return nil
+	}
+	return time.After(timeout - time.Since(d.pendingTime))
+}
+
+func (d *cKeyDispatcher) reset() {
+	d.pending = nil
+}
+
+// setPending 更新暂存的按键，同时更新输入中的提示
+func (d *cKeyDispatcher) setPending(keys []*EventKey) {
+	var ek *EventKey
+	if len(keys) > 0 {
+		ek = keys[0]
+	} else if len(d.pending) > 0 {
+		ek = d.pending[0]
+	}
+	d.pending = keys
+	d.pendingTime = time.Now()
+	if ek == nil {
+		return
+	}
+	if line := eventKeyLine(ek); line != nil {
+		line.pendingKeys = keyHint(keys)
+	}
+}
+
+func eventKeyLine(ek *EventKey) *Line {
+	if ek.cli != nil {
+		return ek.cli.GetLine()
+	}
+	if ek.tcli != nil {
+		return ek.tcli.GetLine()
+	}
+	return nil
+}
+
+// eventKeyNames 返回按键的名字，字符输入的名字就是字符本身
+func eventKeyNames(keys []*EventKey) []string {
+	names := make([]string, len(keys))
+	for i, ek := range keys {
+		if ek.Type() == EventTypeInsertChar {
+			names[i] = string(ek.data)
+			//    避免跟 <ctrl_u> 这种名字的前缀混淆
+			if names[i] == "<" {
+				names[i] = "<lt>"
+			}
+		} else {
+			names[i] = keyName(ek.Type(), ek.modifiers)
+		}
+	}
+	return names
+}
+
+// keyHint 返回组合键前缀的提示文本，跟 Emacs 一样，比如 "C-x-" "Esc-"
+func keyHint(keys []*EventKey) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	names := eventKeyNames(keys)
+	for i, name := range names {
+		switch {
+		case name == EventTypeEscape.String():
+			names[i] = "Esc"
+		case strings.HasPrefix(name, "<ctrl_"):
+			names[i] = "C-" + strings.TrimSuffix(strings.TrimPrefix(name, "<ctrl_"), ">")
+		case strings.HasPrefix(name, "<meta_"):
+			names[i] = "M-" + strings.TrimSuffix(strings.TrimPrefix(name, "<meta_"), ">")
+		case name == "<lt>":
+			names[i] = "<"
+		case strings.HasPrefix(name, "<"):
+			names[i] = strings.TrimSuffix(strings.TrimPrefix(name, "<"), ">")
+		}
+	}
+	return strings.Join(names, " ") + "-"
+}
+
+func runCommand(name string, command Command, ctx *CommandContext) {
+	line := ctx.Line
+	//    增量搜索时，除了搜索相关的命令，其他命令都会先结束搜索
+	if line.mode.Is(linemode.IncrementalSearch) && !searchCommands[name] {
+		line.AcceptSearch()
+	}
+	//    询问是否列出全部补全时，绑定了命令的按键用来回答不列出，不执行命令
+	if line.mode.Is(linemode.CompletionQuery) {
+		line.AnswerCompletionQuery(false)
+		return
+	}
+	if name != "undo" && name != "redo" {
+		line.SaveToUndoStack()
+	}
+	//    跟事件处理器一样，除了选中相关的命令，其他命令都会先取消选中
+	if !selectionCommands[name] {
+		line.ClearSelection()
+	}
+	command(ctx)
+}
+
+// selectionCommands 会扩展、替换或者删除选中文本的命令
+var selectionCommands = map[string]bool{
+	"delete-char":                true,
+	"backward-delete-char":       true,
+	"delete-selection":           true,
+	"copy":                       true,
+	"cut":                        true,
+	"paste":                      true,
+	"select-all":                 true,
+	"select-word":                true,
+	"select-line":                true,
+	"select-backward-char":       true,
+	"select-forward-char":        true,
+	"select-backward-word":       true,
+	"select-forward-word":        true,
+	"select-previous-line":       true,
+	"select-next-line":           true,
+	"select-beginning-of-buffer": true,
+	"select-end-of-buffer":       true,
+}
+
+// selectCommand 返回扩展选中区域的命令
+func selectCommand(move func(l *Line)) Command {
+	return lineCommand(func(line *Line) { line.ExtendSelection(move) })
+}
+
+// searchCommands 增量搜索时由搜索处理的命令
+var searchCommands = map[string]bool{
+	"reverse-search-history": true,
+	"forward-search-history": true,
+	"abort-search":           true,
+	"backward-delete-char":   true,
+}
+
+// lineCommand 返回先退出补全再执行 action 的命令
+func lineCommand(action func(line *Line)) Command {
+	return func(ctx *CommandContext) {
+		ctx.Line.ToNormalMode()
+		action(ctx.Line)
+	}
+}
+
+// builtinCommands 内置命令，名字尽量跟 readline 保持一致
+var builtinCommands = map[string]Command{
+	"ignore": func(_ *CommandContext) {},
+
+	"beginning-of-line":   lineCommand(func(line *Line) { line.CursorToStartOfLine(false) }),
+	"end-of-line":         lineCommand((*Line).CursorToEndOfLine),
+	"beginning-of-buffer": lineCommand((*Line).Home),
+	"end-of-buffer":       lineCommand((*Line).End),
+	"backward-char":       lineCommand((*Line).CursorLeft),
+	"forward-char":        lineCommand((*Line).CursorRight),
+	"backward-word":       lineCommand((*Line).CursorWordBack),
+	"forward-word":        lineCommand((*Line).CursorWordForward),
+	"previous-line": func(ctx *CommandContext) {
+		ctx.Line.AutoUp()
+	},
+	"next-line": func(ctx *CommandContext) {
+		ctx.Line.AutoDown()
+	},
+	"previous-history":      lineCommand((*Line).HistoryBackward),
+	"next-history":          lineCommand((*Line).HistoryForward),
+	"goto-matching-bracket": lineCommand((*Line).GotoMatchingBracket),
+
+	"delete-char": lineCommand(func(line *Line) {
+		if line.HasSelection() {
+			line.DeleteSelection()
+			return
+		}
+		line.DeleteCharacterAfterCursor(1)
+	}),
+	"backward-delete-char": func(ctx *CommandContext) {
+		if ctx.Line.mode.Is(linemode.IncrementalSearch) {
+			ctx.Line.DeleteSearchCharacter()
+			return
+		}
+		ctx.Line.ToNormalMode()
+		if ctx.Line.HasSelection() {
+			ctx.Line.DeleteSelection()
+			return
+		}
+		ctx.Line.DeleteCharacterBeforeCursor(1)
+	},
+	"delete-char-or-exit": func(ctx *CommandContext) {
+		ctx.Line.ToNormalMode()
+		if ctx.Line.HasText() {
+			ctx.Line.DeleteCharacterAfterCursor(1)
+		} else {
+			ctx.Exit()
+		}
+	},
+	"delete-selection":           lineCommand(func(line *Line) { line.DeleteSelection() }),
+	"copy":                       lineCommand((*Line).CopySelection),
+	"cut":                        lineCommand((*Line).CutSelection),
+	"paste":                      lineCommand((*Line).PasteClipboard),
+	"select-all":                 lineCommand((*Line).SelectAll),
+	"select-word":                lineCommand((*Line).SelectWord),
+	"select-line":                lineCommand((*Line).SelectLine),
+	"select-backward-char":       selectCommand((*Line).CursorLeft),
+	"select-forward-char":        selectCommand((*Line).CursorRight),
+	"select-backward-word":       selectCommand((*Line).CursorWordBack),
+	"select-forward-word":        selectCommand((*Line).CursorWordForward),
+	"select-previous-line":       selectCommand((*Line).CursorUp),
+	"select-next-line":           selectCommand((*Line).CursorDown),
+	"select-beginning-of-buffer": selectCommand((*Line).Home),
+	"select-end-of-buffer":       selectCommand((*Line).End),
+	"transpose-chars":            lineCommand((*Line).SwapCharactersBeforeCursor),
+	"kill-line":                  lineCommand((*Line).KillUntilEndOfLine),
+	"backward-kill-line":         lineCommand((*Line).KillFromStartOfLine),
+	"kill-word":                  lineCommand((*Line).KillWord),
+	"backward-kill-word":         lineCommand((*Line).KillWordBeforeCursor),
+	"kill-whole-line":            lineCommand((*Line).KillCurrentLine),
+	"yank":                       lineCommand((*Line).Yank),
+	"yank-pop":                   lineCommand((*Line).YankPop),
+	"undo": func(ctx *CommandContext) {
+		ctx.Line.Undo()
+	},
+	"redo": func(ctx *CommandContext) {
+		ctx.Line.Redo()
+	},
+
+	"reverse-search-history": func(ctx *CommandContext) {
+		ctx.Line.ReverseSearch()
+	},
+	"forward-search-history": func(ctx *CommandContext) {
+		ctx.Line.ForwardSearch()
+	},
+	"abort-search": func(ctx *CommandContext) {
+		ctx.Line.CancelSearch()
+	},
+
+	"complete": func(ctx *CommandContext) {
+		line := ctx.Line
+		if line.mode.Is(linemode.Complete) {
+			line.AcceptComplete()
+		} else if !line.Complete() {
+			line.CompleteNext(1)
+		}
+	},
+	"menu-complete": func(ctx *CommandContext) {
+		ctx.Line.CompleteNext(1)
+	},
+	"menu-complete-backward": func(ctx *CommandContext) {
+		ctx.Line.CompletePrevious(1)
+	},
+	"cancel-complete": func(ctx *CommandContext) {
+		ctx.Line.CancelComplete()
+	},
+
+	"newline": lineCommand((*Line).Newline),
+	"accept-line": func(ctx *CommandContext) {
+		ctx.Line.AutoEnter()
+		if ctx.Line.IsAccept() {
+			ctx.Accept()
+		}
+	},
+	"abort": func(ctx *CommandContext) {
+		ctx.Line.ToNormalMode()
+		ctx.Abort()
+	},
+	"clear-screen": func(ctx *CommandContext) {
+		ctx.ClearScreen()
+	},
+}
@@ -0,0 +1,192 @@
+package startprompt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyBindings_Bind(t *testing.T) {
+	kb := NewKeyBindings()
+	if err := kb.Bind("forward-word", EventTypeCtrlO); err != nil {
+		t.Fatalf("bind error: %v", err)
+	}
+	command, found := kb.Binding(EventTypeCtrlO)
+	if !found || command != "forward-word" {
+		t.Errorf("binding want=forward-word, but got=%q", command)
+	}
+	kb.Unbind(EventTypeCtrlO)
+	if _, found := kb.Binding(EventTypeCtrlO); found {
+		t.Errorf("binding should be removed")
+	}
+
+	if err := kb.Bind("no-such-command", EventTypeCtrlO); err == nil {
+		t.Errorf("bind unknown command should fail")
+	}
+	if err := kb.Bind("forward-word", EventTypeInsertChar); err == nil {
+		t.Errorf("bind insert char should fail")
+	}
+	if err := kb.Bind("forward-word"); err == nil {
+		t.Errorf("bind empty key sequence should fail")
+	}
+}
+
+func TestKeyBindings_Load(t *testing.T) {
+	kb := NewKeyBindings()
+	err := kb.Load(strings.NewReader(`
+# comment
+<ctrl_o>    forward-word
+<ctrl_x><ctrl_u> undo
+`))
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	command, _ := kb.Binding(EventTypeCtrlO)
+	testStringEqual(t, "forward-word", command)
+	command, _ = kb.Binding(EventTypeCtrlX, EventTypeCtrlU)
+	testStringEqual(t, "undo", command)
+
+	for _, text := range []string{
+		"<ctrl_o>",
+		"<no_such_key> undo",
+		"ctrl_o undo",
+		"<ctrl_o> no-such-command",
+	} {
+		if err := kb.Load(strings.NewReader(text)); err == nil {
+			t.Errorf("load %q should fail", text)
+		}
+	}
+}
+
+func TestKeyBindings_Dispatch(t *testing.T) {
+	kb := NewKeyBindings()
+	kb.RegisterCommand("insert-hello", func(ctx *CommandContext) {
+		ctx.Line.InsertText([]rune("hello"), true)
+	})
+	_ = kb.Bind("insert-hello", EventTypeCtrlO)
+	//    覆盖默认的 Ctrl-A
+	_ = kb.Bind("ignore", EventTypeCtrlA)
+	_ = kb.Bind("accept-line", EventTypeF2)
+
+	result, err := RunHeadless(&CommandLineOption{KeyBindings: kb}, "one <ctrl_o><ctrl_a>!<F2>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "one hello!", result.Text)
+	if !result.Accepted {
+		t.Errorf("input should be accepted")
+	}
+}
+
+func TestKeyBindings_Chord(t *testing.T) {
+	kb := NewKeyBindings()
+	kb.RegisterCommand("insert-hello", func(ctx *CommandContext) {
+		ctx.Line.InsertText([]rune("hello"), true)
+	})
+	if err := kb.BindSequence("insert-hello", "<ctrl_x>h"); err != nil {
+		t.Fatalf("bind error: %v", err)
+	}
+	if err := kb.BindSequence("kill-word", "<escape>d"); err != nil {
+		t.Fatalf("bind error: %v", err)
+	}
+	_ = kb.Bind("end-of-line", EventTypeCtrlX, EventTypeCtrlE)
+	_ = kb.BindSequence("insert-hello", "<escape>b")
+	_ = kb.BindSequence("insert-hello", "<ctrl_shift_arrow_up>")
+	for _, text := range []string{"", "h", "<ctrl_x", "<insert_char>h", "<ctrl_x> h", "<shift_ctrl_a>"} {
+		if err := kb.BindSequence("insert-hello", text); err == nil {
+			t.Errorf("bind sequence %q should fail", text)
+		}
+	}
+
+	tests := []struct {
+		script string
+		text   string
+	}{
+		{"ab<ctrl_x>h", "abhello"},
+		{"ab<ctrl_a><ctrl_x><ctrl_e>c", "abc"},
+		//    匹配失败时按键按照单个按键处理
+		{"ab<ctrl_x>z", "abz"},
+		{"ab<ctrl_x><ctrl_x>h", "abhello"},
+		{"ab<ctrl_x><lt>", "ab<"},
+		{"one two<ctrl_a><esc>d", "two"},
+		//    快速按下 Esc 和 b 被识别成 Meta-B ，按照 Esc 组合键处理
+		{"ab<meta_b>", "abhello"},
+		{"ab<esc>b", "abhello"},
+		{"ab<ctrl_shift_arrow_up>", "abhello"},
+		{"ab<shift_arrow_up>", "ab"},
+	}
+	for _, tt := range tests {
+		result, err := RunHeadless(&CommandLineOption{KeyBindings: kb}, tt.script)
+		if err != nil {
+			t.Fatalf("run %q error: %v", tt.script, err)
+		}
+		if result.Text != tt.text {
+			t.Errorf("script %q want=%q, but got=%q", tt.script, tt.text, result.Text)
+		}
+	}
+
+	//    等待后续按键时提示已经按下的前缀
+	result, _ := RunHeadless(&CommandLineOption{KeyBindings: kb}, "ab<ctrl_x>")
+	testStringEqual(t, "> ab\nC-x-", result.Screen())
+	result, _ = RunHeadless(&CommandLineOption{KeyBindings: kb}, "ab<ctrl_x>h")
+	testStringEqual(t, "> abhello", result.Screen())
+
+	//    超时后不再等待后续按键
+	result, _ = RunHeadless(&CommandLineOption{KeyBindings: kb, ChordTimeout: time.Nanosecond}, "ab<ctrl_x>h")
+	testStringEqual(t, "abh", result.Text)
+}
+
+func TestKeyBindings_LessThan(t *testing.T) {
+	kb := NewKeyBindings()
+	kb.RegisterCommand("insert-hello", func(ctx *CommandContext) {
+		ctx.Line.InsertText([]rune("hello"), true)
+	})
+	if err := kb.BindSequence("insert-hello", "<ctrl_x><lt>"); err != nil {
+		t.Fatalf("bind error: %v", err)
+	}
+	if err := kb.BindSequence("insert-hello", "<lt>"); err == nil {
+		t.Errorf("bind sequence %q should fail", "<lt>")
+	}
+
+	//    提示中的按键名字可以再用来绑定
+	keys, err := parseKeySequence("<ctrl_x><lt>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	names := eventKeyNames([]*EventKey{
+		NewEventKey(EventTypeCtrlX, nil, nil, nil),
+		NewEventKey(EventTypeInsertChar, []rune("<"), nil, nil),
+	})
+	testStringEqual(t, strings.Join(names, ""), strings.Join(keys, ""))
+
+	result, err := RunHeadless(&CommandLineOption{KeyBindings: kb}, "ab<ctrl_x><lt>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "abhello", result.Text)
+	result, _ = RunHeadless(&CommandLineOption{KeyBindings: kb}, "ab<ctrl_x>z<lt>")
+	testStringEqual(t, "abz<", result.Text)
+}
+
+func TestKeyBindings_Selection(t *testing.T) {
+	kb := NewKeyBindings()
+	_ = kb.Bind("select-all", EventTypeF2)
+	_ = kb.Bind("select-word", EventTypeF3)
+	_ = kb.Bind("backward-delete-char", EventTypeF4)
+	tests := []struct {
+		script string
+		text   string
+	}{
+		{"one two<F2>x", "x"},
+		{"one two<F3><F4>", "one "},
+		//    其他命令会取消选中
+		{"one two<F2><ctrl_a>x", "xone two"},
+	}
+	for _, tt := range tests {
+		result, err := RunHeadless(&CommandLineOption{KeyBindings: kb}, tt.script)
+		if err != nil {
+			t.Fatalf("run %q error: %v", tt.script, err)
+		}
+		testStringEqual(t, tt.text, result.Text)
+	}
+}
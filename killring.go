@@ -0,0 +1,46 @@
+package startprompt
+
+/*
+kill ring ，保存被删除（kill）的文本，用于粘贴（yank）
+跟 Emacs 一样，连续的删除会合并成一个条目
+*/
+
+// killRingMaxSize kill ring 最多保存的条目数量
+const killRingMaxSize = 60
+
+type cKillRing struct {
+	//    最新的条目在最后面
+	items []string
+}
+
+func newKillRing() *cKillRing {
+	return &cKillRing{}
+}
+
+// add 添加删除的文本
+// merge 为 true 时合并到最新的条目中， prepend 为 true 表示文本放在最新条目的前面（向前删除的情况）
+func (kr *cKillRing) add(text string, merge bool, prepend bool) {
+	if merge && len(kr.items) > 0 {
+		last := len(kr.items) - 1
+		if prepend {
+			kr.items[last] = text + kr.items[last]
+		} else {
+			kr.items[last] += text
+		}
+		return
+	}
+	kr.items = append(kr.items, text)
+	if len(kr.items) > killRingMaxSize {
+		kr.items = kr.items[len(kr.items)-killRingMaxSize:]
+	}
+}
+
+func (kr *cKillRing) length() int {
+	return len(kr.items)
+}
+
+// get 返回第 index 新的条目， index 为 0 表示最新的条目，超出范围时会循环
+func (kr *cKillRing) get(index int) string {
+	n := len(kr.items)
+	return kr.items[n-1-index%n]
+}
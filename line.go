@@ -1,12 +1,15 @@
 package startprompt
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/yetsing/startprompt/enums/linemode"
+	"github.com/yetsing/startprompt/token"
 )
 
 type cCompletionState struct {
@@ -16,6 +19,8 @@ type cCompletionState struct {
 	currentCompletions []*Completion
 	// 当前补全位置
 	completeIndex int
+	// 是否在等待异步补全
+	loading bool
 }
 
 func newCompletionState(
@@ -29,15 +34,83 @@ func newCompletionState(
 	}
 }
 
+// copy 复制补全状态，补全列表不会修改，所以可以共用
+//
+//	副本用于撤销，恢复后不会再收到异步补全，所以不复制加载状态
+func (c *cCompletionState) copy() *cCompletionState {
+	state := *c
+	state.loading = false
+	return &state
+}
+
+// asyncCompleteSpinnerInterval 等待异步补全时加载动画的刷新间隔
+const asyncCompleteSpinnerInterval = 100 * time.Millisecond
+
+// cAsyncCompleteRequest 一次异步补全请求
+type cAsyncCompleteRequest struct {
+	//    请求对应的补全状态，状态被替换说明请求已经过时
+	state *cCompletionState
+	//    补全到来时是否选中第一个补全
+	gotoFirst bool
+	cancel    context.CancelFunc
+}
+
 func (c *cCompletionState) originalCursorPosition() int {
 	return c.originalDocument.CursorPosition()
 }
 
-func (c *cCompletionState) currentCompletionText() string {
+// currentCompletion 返回当前选中的补全，没有选中时返回 nil
+func (c *cCompletionState) currentCompletion() *Completion {
 	if c.completeIndex == -1 {
-		return ""
-	} else {
-		return c.currentCompletions[c.completeIndex].Suffix
+		return nil
+	}
+	return c.currentCompletions[c.completeIndex]
+}
+
+// cIncrementalSearchState 增量搜索状态
+type cIncrementalSearchState struct {
+	//    搜索文本
+	text []rune
+	//    是否往更早的历史输入搜索（Ctrl-R 为 true ， Ctrl-S 为 false）
+	backward bool
+	//    是否没有找到匹配
+	failed bool
+	//    搜索开始时的 workingIndex 和光标位置，取消搜索时恢复
+	originalWorkingIndex   int
+	originalCursorPosition int
+	//    每次追加搜索文本前的位置，删除搜索文本时回到对应位置
+	positionStack []_SearchPosition
+}
+
+type _SearchPosition struct {
+	workingIndex   int
+	cursorPosition int
+	failed         bool
+}
+
+func newIncrementalSearchState(
+	originalWorkingIndex int,
+	originalCursorPosition int,
+	backward bool,
+) *cIncrementalSearchState {
+	return &cIncrementalSearchState{
+		backward:               backward,
+		originalWorkingIndex:   originalWorkingIndex,
+		originalCursorPosition: originalCursorPosition,
+	}
+}
+
+// getPrompt 返回搜索时代替原有提示符的 token ，类似 bash 的 (reverse-i-search)`text':
+func (s *cIncrementalSearchState) getPrompt() []token.Token {
+	name := "i-search"
+	if s.backward {
+		name = "reverse-i-search"
+	}
+	if s.failed {
+		name = "failed " + name
+	}
+	return []token.Token{
+		token.NewToken(token.IncrementalSearchPrompt, fmt.Sprintf("(%s)`%s': ", name, string(s.text))),
 	}
 }
 
@@ -47,9 +120,28 @@ type _LineArea struct {
 	end   int
 }
 
+// _EditState 某一时刻的文本和光标位置
+type _EditState struct {
+	text           string
+	cursorPosition int
+}
+
+// _YankState 上一次粘贴（yank）的状态
+type _YankState struct {
+	//    粘贴完成后的文本和光标位置
+	_EditState
+	//    粘贴文本的开始位置，结束位置就是光标位置
+	start int
+	//    粘贴的是 kill ring 中第几新的条目
+	index int
+}
+
 type _UndoEntry struct {
 	text           string
 	cursorPosition int
+	selection      _LineArea
+	//    补全状态的副本，不在补全时为 nil
+	completeState *cCompletionState
 }
 
 type Line struct {
@@ -59,8 +151,33 @@ type Line struct {
 	//    光标在文本 buffer 中的位置
 	cursorPosition int
 	undoStack      []*_UndoEntry
+	redoStack      []*_UndoEntry
 	mode           linemode.LineMode
 	completeState  *cCompletionState
+	isearchState   *cIncrementalSearchState
+	//    上一次增量搜索的文本，搜索文本为空时再次按下 Ctrl-R 会沿用
+	lastSearchText []rune
+	//    保存删除的文本，同一个 CommandLine 的多次输入共用
+	killRing *cKillRing
+	//    上一次删除后的状态，当前状态跟它一样时，说明中间没有其他操作，删除的文本会合并
+	lastKill *_EditState
+	//    上一次粘贴后的状态，用来判断能不能执行 YankPop
+	lastYank *_YankState
+	//    复制、剪切和粘贴使用的剪贴板
+	clipboard Clipboard
+	//    在事件循环中执行修改，异步补全通过它将补全交给 Line
+	//    为 nil 时（比如 RunHeadless ）同步等待异步补全
+	invoke func(fn func(line *Line))
+	//    正在进行的异步补全请求
+	asyncComplete *cAsyncCompleteRequest
+	//    gotoCompletion 修改文本时为 true ，其他修改会取消正在进行的异步补全
+	applyingCompletion bool
+	//    补全的展示方式
+	completionDisplay cCompletionDisplayOption
+	//    等待用户回答是否列出的补全（见 CompletionDisplayReadline）
+	completionQuery []*Completion
+	//    等待 CommandLine 或 TCommandLine 在输入下方列出的补全
+	completionListing []*Completion
 
 	codeFactory   CodeFactory
 	promptFactory PromptFactory
@@ -75,6 +192,13 @@ type Line struct {
 	//    用户是否确定本次输入
 	accept bool
 
+	//    编辑模式，比如 vi 的 normal insert visual 模式，默认的编辑方式下为空
+	editMode linemode.LineMode
+	//    组合键已经按下的前缀（比如 "C-x-"），等待后续按键时提示给用户
+	pendingKeys string
+	//    上一次确定输入时的检查错误，修改文本后清除
+	validationError *ValidationError
+
 	//    选中区域
 	selection _LineArea
 	//    取消选中
@@ -92,6 +216,13 @@ func newLine(
 		codeFactory:    codeFactory,
 		history:        history,
 		cursorPosition: 0,
+		killRing:       newKillRing(),
+		clipboard:      NewMemClipboard(),
+		completionDisplay: cCompletionDisplayOption{
+			display:    CompletionDisplayColumn,
+			menuHeight: defaultCompletionMenuHeight,
+			queryItems: defaultCompletionQueryItems,
+		},
 
 		autoIndent: autoIndent,
 	}
@@ -101,12 +232,23 @@ func newLine(
 
 func (l *Line) reset() {
 	l.mode = linemode.Normal
+	l.editMode = ""
+	l.pendingKeys = ""
+	l.validationError = nil
 	l.buffer = nil
 	l.cursorPosition = 0
+	l.selection = _LineArea{-1, -1}
 
+	l.cancelAsyncComplete()
 	l.completeState = nil
+	l.completionQuery = nil
+	l.completionListing = nil
+	l.isearchState = nil
 
 	l.undoStack = nil
+	l.redoStack = nil
+	l.lastKill = nil
+	l.lastYank = nil
 
 	lines := l.history.GetAll()
 	// +1 是因为当前输入也要占个位置
@@ -125,6 +267,18 @@ func (l *Line) setText(buffer []rune) {
 	l.textChanged()
 }
 
+// SetText 替换输入的文本并设置光标位置，替换前的状态可以通过 undo 恢复
+//
+//	会先退出补全和增量搜索，旧文本的补全不能用在新文本上
+func (l *Line) SetText(text string, cursor int) {
+	l.cancelAsyncComplete()
+	l.ToNormalMode()
+	l.SaveToUndoStack()
+	l.ClearSelection()
+	l.setText([]rune(text))
+	l.SetCursorPosition(minInt(cursor, len(l.buffer)))
+}
+
 func (l *Line) GetCursorPosition() int {
 	return l.cursorPosition
 }
@@ -143,20 +297,52 @@ func (l *Line) setWorkingIndex(value int) {
 }
 
 func (l *Line) textChanged() {
+	//    有新的修改，之前撤销的操作不能再恢复了
+	l.redoStack = nil
+	l.validationError = nil
+	//    文本被补全以外的操作修改了（比如 Invoke ），还没到来的补全已经过时
+	if l.asyncComplete != nil && !l.applyingCompletion {
+		l.AcceptComplete()
+	}
 }
 
-// SaveToUndoStack 保存当前信息（文本和光标位置），支持 undo 操作
+// SaveToUndoStack 保存当前信息（文本、光标位置、选中区域和补全状态），支持 undo 操作
 func (l *Line) SaveToUndoStack() {
-	// 如果文本与最后一个的相同，只更新光标位置
+	// 如果文本与最后一个的相同，只更新其他信息
 	length := len(l.undoStack)
 	if length > 0 && l.undoStack[length-1].text == l.text() {
-		l.undoStack[length-1].cursorPosition = l.cursorPosition
+		l.undoStack[length-1] = l.newUndoEntry()
 	} else {
-		entry := &_UndoEntry{
-			text:           l.text(),
-			cursorPosition: l.cursorPosition,
-		}
-		l.undoStack = append(l.undoStack, entry)
+		l.undoStack = append(l.undoStack, l.newUndoEntry())
+	}
+}
+
+func (l *Line) newUndoEntry() *_UndoEntry {
+	entry := &_UndoEntry{
+		text:           l.text(),
+		cursorPosition: l.cursorPosition,
+		selection:      l.selection,
+	}
+	if l.mode.Is(linemode.Complete) && l.completeState != nil {
+		entry.completeState = l.completeState.copy()
+	}
+	return entry
+}
+
+// restoreUndoEntry 恢复到 entry 保存的状态
+func (l *Line) restoreUndoEntry(entry *_UndoEntry) {
+	//    不调用 setText ，因为 textChanged 会清空 redoStack
+	l.buffer = []rune(entry.text)
+	l.workingLines[l.workingIndex] = entry.text
+	l.SetCursorPosition(entry.cursorPosition)
+	l.selection = entry.selection
+	l.cancelAsyncComplete()
+	if entry.completeState != nil {
+		l.completeState = entry.completeState.copy()
+		l.mode = linemode.Complete
+	} else if l.mode.Is(linemode.Complete) {
+		l.completeState = nil
+		l.mode = linemode.Normal
 	}
 }
 
@@ -235,6 +421,7 @@ func (l *Line) CursorDown() {
 // 如果光标不在第一行，移动光标到上一行
 // 否则切换到上一个历史输入
 func (l *Line) AutoUp() {
+	l.AcceptSearch()
 	if l.mode.Is(linemode.Complete) {
 		l.CompletePrevious(1)
 	} else if l.Document().CursorPositionRow() > 0 {
@@ -249,6 +436,7 @@ func (l *Line) AutoUp() {
 // 如果光标不在第一行，移动光标到下一行
 // 否则切换到下一个历史输入
 func (l *Line) AutoDown() {
+	l.AcceptSearch()
 	if l.mode.In(linemode.Complete) {
 		l.CompleteNext(1)
 	} else if !l.Document().OnLastLine() {
@@ -258,6 +446,26 @@ func (l *Line) AutoDown() {
 	}
 }
 
+// AutoLeft 多列补全菜单中选择左边一列的补全，否则结束补全并左移光标
+func (l *Line) AutoLeft() {
+	if l.mode.Is(linemode.Complete) && l.completionDisplay.display == CompletionDisplayMultiColumn {
+		l.CompletePreviousColumn()
+		return
+	}
+	l.ToNormalMode()
+	l.CursorLeft()
+}
+
+// AutoRight 多列补全菜单中选择右边一列的补全，否则结束补全并右移光标
+func (l *Line) AutoRight() {
+	if l.mode.Is(linemode.Complete) && l.completionDisplay.display == CompletionDisplayMultiColumn {
+		l.CompleteNextColumn()
+		return
+	}
+	l.ToNormalMode()
+	l.CursorRight()
+}
+
 // CursorWordBack 移动光标到前一个单词的开头
 func (l *Line) CursorWordBack() {
 	l.SetCursorPosition(l.cursorPosition + l.Document().findStartOfPreviousWord())
@@ -366,6 +574,101 @@ func (l *Line) DeleteCurrentLine() string {
 	return deleted
 }
 
+// KillWord 删除光标后的单词，并保存到 kill ring
+func (l *Line) KillWord() {
+	l.kill(l.DeleteWord, false)
+}
+
+// KillWordBeforeCursor 删除光标前的单词，并保存到 kill ring
+func (l *Line) KillWordBeforeCursor() {
+	l.kill(l.DeleteWordBeforeCursor, true)
+}
+
+// KillUntilEndOfLine 删除从光标到行尾处的字符，并保存到 kill ring
+func (l *Line) KillUntilEndOfLine() {
+	l.kill(l.DeleteUntilEndOfLine, false)
+}
+
+// KillFromStartOfLine 删除从行首到光标处的字符，并保存到 kill ring
+func (l *Line) KillFromStartOfLine() {
+	l.kill(l.DeleteFromStartOfLine, true)
+}
+
+// KillCurrentLine 删除当前行，并保存到 kill ring
+func (l *Line) KillCurrentLine() {
+	l.kill(l.DeleteCurrentLine, false)
+}
+
+// kill 调用 deleteFunc 删除文本，并保存到 kill ring
+// backward 表示是否是向前删除，连续删除时，向前删除的文本会放在前面
+func (l *Line) kill(deleteFunc func() string, backward bool) {
+	merge := l.lastKill != nil && l.isEditState(*l.lastKill)
+	deleted := deleteFunc()
+	if deleted == "" {
+		return
+	}
+	l.killRing.add(deleted, merge, backward)
+	l.lastKill = &_EditState{text: l.text(), cursorPosition: l.cursorPosition}
+}
+
+// Yank 在光标处插入 kill ring 中最新的文本
+func (l *Line) Yank() {
+	if l.killRing.length() == 0 {
+		return
+	}
+	l.yank(0)
+}
+
+// YankPop 上一次操作是 Yank 或者 YankPop 时，将粘贴的文本替换为 kill ring 中更早的文本
+func (l *Line) YankPop() {
+	if l.lastYank == nil || !l.isEditState(l.lastYank._EditState) {
+		return
+	}
+	start := l.lastYank.start
+	index := l.lastYank.index + 1
+	l.removeRunes(start, l.cursorPosition-start)
+	l.SetCursorPosition(start)
+	l.yank(index)
+}
+
+func (l *Line) yank(index int) {
+	start := l.cursorPosition
+	l.insertText([]rune(l.killRing.get(index)), true)
+	l.lastYank = &_YankState{
+		_EditState: _EditState{text: l.text(), cursorPosition: l.cursorPosition},
+		start:      start,
+		index:      index % l.killRing.length(),
+	}
+}
+
+// isEditState 当前的文本和光标位置是否跟 state 一样
+func (l *Line) isEditState(state _EditState) bool {
+	return l.cursorPosition == state.cursorPosition && l.text() == state.text
+}
+
+// KillRegion 删除 [start, end) 之间的文本，并保存到 kill ring ，光标会移动到 start
+func (l *Line) KillRegion(start int, end int) string {
+	start, end = maxInt(start, 0), minInt(end, len(l.buffer))
+	if start >= end {
+		return ""
+	}
+	deleted := string(l.removeRunes(start, end-start))
+	l.SetCursorPosition(start)
+	l.killRing.add(deleted, false, false)
+	return deleted
+}
+
+// CopyRegion 复制 [start, end) 之间的文本到 kill ring
+func (l *Line) CopyRegion(start int, end int) string {
+	start, end = maxInt(start, 0), minInt(end, len(l.buffer))
+	if start >= end {
+		return ""
+	}
+	copied := string(l.buffer[start:end])
+	l.killRing.add(copied, false, false)
+	return copied
+}
+
 // JoinNextLine 将当前行和下一行拼接为一行
 func (l *Line) JoinNextLine() {
 	l.CursorToEndOfLine()
@@ -394,59 +697,7 @@ func (l *Line) GotoMatchingBracket() {
 }
 
 func (l *Line) getMatchingBracket() int {
-	brackets := []struct {
-		left  string
-		right string
-	}{
-		{"(", ")"},
-		{"[", "]"},
-		{"{", "}"},
-		{"<", ">"},
-	}
-	document := l.Document()
-	stack := 1
-	for _, bracket := range brackets {
-		if document.CurrentChar() == bracket.left {
-			// 寻找匹配的右括号
-			text := document.TextAfterCursor()
-			step := 0
-			for _, r := range stringStartAt(text, 1) {
-				if string(r) == bracket.left {
-					stack++
-				} else if string(r) == bracket.right {
-					stack--
-				}
-				if stack == 0 {
-					// 是从 1 开始遍历的，所以这里需要加 1
-					// l.SetCursorPosition(l.cursorPosition + step + 1)
-					// break
-					return l.cursorPosition + step + 1
-				}
-				step++
-			}
-		} else if document.CurrentChar() == bracket.right {
-			// 寻找匹配的左括号
-			text := document.TextBeforeCursor()
-			text = reverseString(text)
-			step := 0
-			for _, r := range text {
-				if string(r) == bracket.right {
-					stack++
-				} else if string(r) == bracket.left {
-					stack--
-				}
-				if stack == 0 {
-					// 比如这种情况 () 光标在括号中间
-					// stack == 0 的时候， step = 0 ，需要向左移动一格，所以还需要减一
-					// l.SetCursorPosition(l.cursorPosition - step - 1)
-					// break
-					return l.cursorPosition - step - 1
-				}
-				step++
-			}
-		}
-	}
-	return -1
+	return l.Document().findMatchingBracket()
 }
 
 func (l *Line) CreateCode() Code {
@@ -475,6 +726,10 @@ func (l *Line) CompleteNext(count int) {
 		l.StartComplete(true)
 	} else {
 		completionsCount := len(l.completeState.currentCompletions)
+		//    异步补全还没有到来
+		if completionsCount == 0 {
+			return
+		}
 
 		var index int
 		if l.completeState.completeIndex == -1 {
@@ -495,7 +750,7 @@ func (l *Line) CompletePrevious(count int) {
 		l.StartComplete(false)
 	}
 
-	if l.completeState != nil {
+	if l.completeState != nil && len(l.completeState.currentCompletions) > 0 {
 		var index int
 		if l.completeState.completeIndex == -1 {
 			index = len(l.completeState.currentCompletions) - 1
@@ -511,10 +766,141 @@ func (l *Line) CompletePrevious(count int) {
 
 // StartComplete 开始补全
 func (l *Line) StartComplete(gotoFirst bool) {
-	currentCompletions := l.CreateCode().GetCompletions()
+	l.startComplete(gotoFirst)
+}
 
-	if len(currentCompletions) > 0 {
-		l.completeState = newCompletionState(l.Document(), currentCompletions)
+// startComplete 开始补全，返回 false 表示没有补全（异步补全总是返回 true）
+func (l *Line) startComplete(gotoFirst bool) bool {
+	l.cancelAsyncComplete()
+	code := l.CreateCode()
+	if completer, ok := code.(AsyncCompleter); ok {
+		l.startAsyncComplete(completer, gotoFirst)
+		return true
+	}
+	completions := code.GetCompletions()
+	l.setCompletions(completions, gotoFirst)
+	return len(completions) > 0
+}
+
+// startAsyncComplete 开始异步补全，补全在后台协程获取，通过 invoke 交给 Line
+func (l *Line) startAsyncComplete(completer AsyncCompleter, gotoFirst bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := completer.GetCompletionsAsync(ctx)
+	if l.invoke == nil {
+		//    没有事件循环，同步等待全部补全
+		var completions []*Completion
+		for completion := range ch {
+			completions = append(completions, completion)
+		}
+		cancel()
+		l.setCompletions(completions, gotoFirst)
+		return
+	}
+
+	state := newCompletionState(l.Document(), nil)
+	state.loading = true
+	request := &cAsyncCompleteRequest{state: state, gotoFirst: gotoFirst, cancel: cancel}
+	l.completeState = state
+	l.mode = linemode.Complete
+	l.asyncComplete = request
+
+	invoke := l.invoke
+	go func() {
+		//    定时重绘，让加载动画动起来
+		ticker := time.NewTicker(asyncCompleteSpinnerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				//    请求已经取消，不再接收补全，也不再重绘
+				return
+			case completion, ok := <-ch:
+				if !ok {
+					if ctx.Err() == nil {
+						invoke(func(line *Line) { line.finishAsyncComplete(request) })
+					}
+					return
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				//    一次取出已经到来的补全，减少重绘次数
+				completions := []*Completion{completion}
+				reading := true
+				for reading {
+					select {
+					case completion, ok = <-ch:
+						if ok {
+							completions = append(completions, completion)
+						} else {
+							reading = false
+						}
+					default:
+						reading = false
+					}
+				}
+				invoke(func(line *Line) { line.addAsyncCompletions(request, completions) })
+			case <-ticker.C:
+				if ctx.Err() != nil {
+					return
+				}
+				invoke(func(line *Line) {})
+			}
+		}
+	}()
+}
+
+// addAsyncCompletions 添加异步补全，过时的请求会被忽略
+func (l *Line) addAsyncCompletions(request *cAsyncCompleteRequest, completions []*Completion) {
+	if l.asyncComplete != request || l.completeState != request.state {
+		return
+	}
+	state := request.state
+	state.currentCompletions = append(state.currentCompletions, completions...)
+	//    readline 的展示方式在全部补全到来后才处理
+	if request.gotoFirst && state.completeIndex == -1 && l.completionDisplay.display != CompletionDisplayReadline {
+		l.gotoCompletion(0)
+	}
+}
+
+// finishAsyncComplete 异步补全全部到来
+func (l *Line) finishAsyncComplete(request *cAsyncCompleteRequest) {
+	if l.asyncComplete != request {
+		return
+	}
+	request.cancel()
+	l.asyncComplete = nil
+	if l.completeState != request.state {
+		return
+	}
+	request.state.loading = false
+	if l.completionDisplay.display == CompletionDisplayReadline {
+		l.listCompletions(request.state.currentCompletions)
+		return
+	}
+	if len(request.state.currentCompletions) == 0 {
+		l.mode = linemode.Normal
+		l.completeState = nil
+	}
+}
+
+// cancelAsyncComplete 取消正在进行的异步补全
+func (l *Line) cancelAsyncComplete() {
+	if l.asyncComplete != nil {
+		l.asyncComplete.cancel()
+		l.asyncComplete.state.loading = false
+		l.asyncComplete = nil
+	}
+}
+
+// setCompletions 设置补全列表，没有补全时退出补全
+func (l *Line) setCompletions(completions []*Completion, gotoFirst bool) {
+	if l.completionDisplay.display == CompletionDisplayReadline {
+		l.listCompletions(completions)
+		return
+	}
+	if len(completions) > 0 {
+		l.completeState = newCompletionState(l.Document(), completions)
 		l.mode = linemode.Complete
 		if gotoFirst {
 			l.gotoCompletion(0)
@@ -525,8 +911,113 @@ func (l *Line) StartComplete(gotoFirst bool) {
 	}
 }
 
+// listCompletions 跟 readline 一样处理补全（见 CompletionDisplayReadline ）
+//
+//	只有一个补全时直接使用，补全有共同前缀时补全前缀，
+//	否则交给 CommandLine 或 TCommandLine 在输入下方列出，补全太多时先询问用户
+func (l *Line) listCompletions(completions []*Completion) {
+	l.mode = linemode.Normal
+	l.completeState = nil
+	switch len(completions) {
+	case 0:
+		return
+	case 1:
+		l.completeState = newCompletionState(l.Document(), completions)
+		l.mode = linemode.Complete
+		l.gotoCompletion(0)
+		l.AcceptComplete()
+		return
+	}
+	if l.insertCommonPrefix(completions) {
+		return
+	}
+	if len(completions) > l.completionDisplay.queryItems {
+		l.completionQuery = completions
+		l.mode = linemode.CompletionQuery
+	} else {
+		l.completionListing = completions
+	}
+}
+
+// insertCommonPrefix 补全替换的位置相同并且有比光标前的文本更长的共同前缀时，
+// 用共同前缀替换光标前的文本，返回是否替换了
+func (l *Line) insertCommonPrefix(completions []*Completion) bool {
+	start, prefix := completions[0].replacement()
+	for _, completion := range completions[1:] {
+		s, text := completion.replacement()
+		if s != start {
+			return false
+		}
+		prefix = commonPrefix(prefix, text)
+	}
+	from := l.cursorPosition + start
+	if from < 0 {
+		return false
+	}
+	replaced := string(l.buffer[from:l.cursorPosition])
+	prefixRunes := []rune(prefix)
+	//    忽略大小写，比如输入 "ap" 时共同前缀可以是 "Ap"
+	if len(prefixRunes) <= -start || !strings.EqualFold(string(prefixRunes[:-start]), replaced) {
+		return false
+	}
+	l.removeRunes(from, -start)
+	l.SetCursorPosition(from)
+	l.insertText(prefixRunes, true)
+	return true
+}
+
+// AnswerCompletionQuery 回答是否列出全部补全， yes 为 false 时放弃列出
+func (l *Line) AnswerCompletionQuery(yes bool) {
+	if !l.mode.Is(linemode.CompletionQuery) {
+		return
+	}
+	if yes {
+		l.completionListing = l.completionQuery
+	}
+	l.completionQuery = nil
+	l.mode = linemode.Normal
+}
+
+// takeCompletionListing 返回并清空等待列出的补全
+func (l *Line) takeCompletionListing() []*Completion {
+	completions := l.completionListing
+	l.completionListing = nil
+	return completions
+}
+
+// CompleteNextColumn 多列补全菜单中选择右边一列的补全，已经在最后一列时不动
+func (l *Line) CompleteNextColumn() {
+	if !l.mode.Is(linemode.Complete) || len(l.completeState.currentCompletions) == 0 {
+		return
+	}
+	count := len(l.completeState.currentCompletions)
+	rows := l.completionDisplay.rows(count)
+	index := l.completeState.completeIndex
+	if index == -1 {
+		l.gotoCompletion(0)
+		return
+	}
+	if index/rows == (count-1)/rows {
+		return
+	}
+	//    最后一列不满时选择最后一个补全
+	l.gotoCompletion(minInt(index+rows, count-1))
+}
+
+// CompletePreviousColumn 多列补全菜单中选择左边一列的补全，已经在第一列时不动
+func (l *Line) CompletePreviousColumn() {
+	if !l.mode.Is(linemode.Complete) {
+		return
+	}
+	rows := l.completionDisplay.rows(len(l.completeState.currentCompletions))
+	if index := l.completeState.completeIndex; index >= rows {
+		l.gotoCompletion(index - rows)
+	}
+}
+
 // AcceptComplete 接受当前选中的补全
 func (l *Line) AcceptComplete() {
+	l.cancelAsyncComplete()
 	l.mode = linemode.Normal
 	l.completeState = nil
 }
@@ -534,6 +1025,7 @@ func (l *Line) AcceptComplete() {
 // CancelComplete 取消补全
 func (l *Line) CancelComplete() {
 	if l.mode.Is(linemode.Complete) {
+		l.cancelAsyncComplete()
 		l.gotoCompletion(-1)
 		l.mode = linemode.Normal
 		l.completeState = nil
@@ -546,15 +1038,25 @@ func (l *Line) gotoCompletion(index int) {
 		panic(fmt.Sprintf("line mode want=Complete, but got=%s", l.mode))
 	}
 
-	// 撤销之前的补全
-	count := utf8.RuneCountInString(l.completeState.currentCompletionText())
-	if count > 0 {
-		l.DeleteCharacterBeforeCursor(count)
-	}
+	l.applyingCompletion = true
+	defer func() { l.applyingCompletion = false }()
 
-	// 设置新的补全
-	l.completeState.completeIndex = index
-	l.insertText([]rune(l.completeState.currentCompletionText()), true)
+	// 撤销之前的补全，恢复到补全开始时的文本和光标位置
+	state := l.completeState
+	if l.text() != state.originalDocument.Text() {
+		l.setText([]rune(state.originalDocument.Text()))
+	}
+	l.SetCursorPosition(state.originalCursorPosition())
+
+	// 设置新的补全，替换光标前面的文本
+	state.completeIndex = index
+	if completion := state.currentCompletion(); completion != nil {
+		start, text := completion.replacement()
+		start = maxInt(l.cursorPosition+start, 0)
+		l.removeRunes(start, l.cursorPosition-start)
+		l.SetCursorPosition(start)
+		l.insertText([]rune(text), true)
+	}
 
 	l.mode = linemode.Complete
 }
@@ -570,8 +1072,24 @@ func (l *Line) GetRenderContext() *RenderContext {
 	}
 
 	var highlights []section
+	var searchMatches []section
+	var isearchState *cIncrementalSearchState
 	document := l.Document()
-	matchIndex := l.getMatchingBracket()
+	matchIndex := -1
+	if l.mode.Is(linemode.IncrementalSearch) {
+		isearchState = l.isearchState
+		//    高亮匹配的搜索文本
+		if !isearchState.failed && len(isearchState.text) > 0 {
+			startRow, startCol := document.translateIndexToRowCol(l.cursorPosition)
+			endRow, endCol := document.translateIndexToRowCol(l.cursorPosition + len(isearchState.text))
+			searchMatches = append(searchMatches, section{
+				Location{startRow, startCol},
+				Location{endRow, endCol},
+			})
+		}
+	} else {
+		matchIndex = l.getMatchingBracket()
+	}
 	if matchIndex >= 0 {
 		r, c := document.translateIndexToRowCol(matchIndex)
 		start := Location{r, c}
@@ -584,12 +1102,31 @@ func (l *Line) GetRenderContext() *RenderContext {
 		highlights = append(highlights, section{start, end})
 	}
 
+	var selections []section
+	if l.HasSelection() {
+		start, end := l.selectionRange()
+		startRow, startCol := document.translateIndexToRowCol(start)
+		endRow, endCol := document.translateIndexToRowCol(end)
+		selections = append(selections, section{
+			Location{startRow, startCol},
+			Location{endRow, endCol},
+		})
+	}
+
 	renderCtx := newRenderContext(
 		code,
 		completeState,
+		isearchState,
 		document,
 		highlights,
+		searchMatches,
+		selections,
 		l.cancelSelection,
+		l.editMode,
+		l.pendingKeys,
+		l.validationError,
+		l.completionDisplay,
+		len(l.completionQuery),
 	)
 	l.cancelSelection = false
 	return renderCtx
@@ -623,6 +1160,8 @@ func (l *Line) Newline() {
 
 // AutoEnter 自动处理 Enter
 func (l *Line) AutoEnter() {
+	//    跟 bash 一样，搜索时按下 Enter 会结束搜索并直接处理找到的输入
+	l.AcceptSearch()
 	if l.mode.Is(linemode.Complete) {
 		l.AcceptComplete()
 		return
@@ -687,11 +1226,7 @@ func (l *Line) OverwriteText(data []rune, moveCursor bool) {
 // moveCursor 表示插入后是否移动光标
 func (l *Line) InsertText(data []rune, moveCursor bool) {
 	//    输入中有选中文本，直接删除
-	if l.selection.start != -1 && l.selection.end > l.selection.start {
-		l.DeleteCharacterBeforeCursor(l.selection.end - l.selection.start)
-		l.selection = _LineArea{-1, -1}
-		l.cancelSelection = true
-	}
+	l.DeleteSelection()
 	l.insertText(data, moveCursor)
 
 	if l.CompleteAfterInsertText() {
@@ -699,6 +1234,11 @@ func (l *Line) InsertText(data []rune, moveCursor bool) {
 	}
 }
 
+// PasteText 插入粘贴的文本，文本原样插入，不会自动缩进，也不会触发补全
+func (l *Line) PasteText(data []rune) {
+	l.ReplaceSelection(data)
+}
+
 func (l *Line) insertText(data []rune, moveCursor bool) {
 	result := insertRunes(l.buffer, l.cursorPosition, data)
 	// result := concatRunes(l.buffer[:l.cursorPosition], data, l.buffer[l.cursorPosition:])
@@ -719,23 +1259,53 @@ func (l *Line) removeRunes(index int, count int) []rune {
 	if removeEnd > len(l.buffer) {
 		removeEnd = len(l.buffer)
 	}
-	ret := buffer[index:removeEnd]
+	//    复制一份，因为下面的 append 会覆盖 buffer 原来的内容
+	ret := make([]rune, removeEnd-index)
+	copy(ret, buffer[index:removeEnd])
 	buffer = append(buffer[:index], buffer[removeEnd:]...)
 	l.setText(buffer)
 	return ret
 }
 
+// Undo 撤销上一次修改，撤销的修改可以通过 Redo 恢复
 func (l *Line) Undo() {
-	if len(l.undoStack) > 0 {
-		top := l.undoStack[len(l.undoStack)-1]
+	//    跳过文本跟当前一样的记录，否则撤销看起来没有效果
+	for len(l.undoStack) > 0 && l.undoStack[len(l.undoStack)-1].text == l.text() {
 		l.undoStack = l.undoStack[:len(l.undoStack)-1]
-		l.setText([]rune(top.text))
-		l.SetCursorPosition(top.cursorPosition)
 	}
+	if len(l.undoStack) == 0 {
+		return
+	}
+	top := l.undoStack[len(l.undoStack)-1]
+	l.undoStack = l.undoStack[:len(l.undoStack)-1]
+	l.redoStack = append(l.redoStack, l.newUndoEntry())
+	l.restoreUndoEntry(top)
+}
+
+// Redo 恢复上一次撤销的修改
+func (l *Line) Redo() {
+	if len(l.redoStack) == 0 {
+		return
+	}
+	top := l.redoStack[len(l.redoStack)-1]
+	l.redoStack = l.redoStack[:len(l.redoStack)-1]
+	l.undoStack = append(l.undoStack, l.newUndoEntry())
+	l.restoreUndoEntry(top)
 }
 
 // AcceptInput 确定用户输入（一般是用户按下 Enter）
+//
+//	Code 实现了 Validator 时，输入无效不会确定，光标移动到错误的位置
 func (l *Line) AcceptInput() {
+	l.cancelAsyncComplete()
+	if validator, ok := l.CreateCode().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			l.validationError = err
+			l.SetCursorPosition(minInt(err.Position, len(l.buffer)))
+			return
+		}
+	}
+	l.validationError = nil
 	text := l.text()
 
 	// 文本与最后一个不相同时，保存到历史中
@@ -762,6 +1332,16 @@ func (l *Line) IsMultiline() bool {
 	return res
 }
 
+// EditMode 返回编辑模式，默认的编辑方式下返回空字符串
+func (l *Line) EditMode() linemode.LineMode {
+	return l.editMode
+}
+
+// SetEditMode 设置编辑模式，编辑模式会传给提示符，见 ModePrompt
+func (l *Line) SetEditMode(mode linemode.LineMode) {
+	l.editMode = mode
+}
+
 func (l *Line) ToNormalMode() {
 	l.ToMode(linemode.Normal)
 }
@@ -772,11 +1352,282 @@ func (l *Line) ToMode(modes ...linemode.LineMode) {
 	}
 	// todo something
 	if l.mode.Is(linemode.IncrementalSearch) {
+		l.AcceptSearch()
 	} else if l.mode.Is(linemode.Complete) {
 		l.AcceptComplete()
+	} else if l.mode.Is(linemode.CompletionQuery) {
+		l.AnswerCompletionQuery(false)
 	}
 }
 
+// ReverseSearch 往更早的历史输入增量搜索（Ctrl-R）
+// 不在搜索状态时开始搜索，否则跳到上一个匹配
+func (l *Line) ReverseSearch() {
+	l.incrementalSearch(true)
+}
+
+// ForwardSearch 往更新的历史输入增量搜索（Ctrl-S）
+// 不在搜索状态时开始搜索，否则跳到下一个匹配
+func (l *Line) ForwardSearch() {
+	l.incrementalSearch(false)
+}
+
+func (l *Line) incrementalSearch(backward bool) {
+	if !l.mode.Is(linemode.IncrementalSearch) {
+		l.ToNormalMode()
+		l.isearchState = newIncrementalSearchState(l.workingIndex, l.cursorPosition, backward)
+		l.mode = linemode.IncrementalSearch
+		return
+	}
+
+	state := l.isearchState
+	state.backward = backward
+	if len(state.text) == 0 {
+		//    搜索文本为空，沿用上一次的搜索文本
+		state.text = append(state.text, l.lastSearchText...)
+		if len(state.text) > 0 {
+			l.searchText(true)
+		}
+		return
+	}
+	l.searchText(false)
+}
+
+// InsertSearchText 在搜索文本后面追加 data ，并从当前匹配位置继续搜索
+func (l *Line) InsertSearchText(data []rune) {
+	if !l.mode.Is(linemode.IncrementalSearch) {
+		return
+	}
+	state := l.isearchState
+	state.positionStack = append(state.positionStack, _SearchPosition{
+		workingIndex:   l.workingIndex,
+		cursorPosition: l.cursorPosition,
+		failed:         state.failed,
+	})
+	state.text = append(state.text, data...)
+	l.searchText(true)
+}
+
+// DeleteSearchCharacter 删除搜索文本的最后一个字符，并回到追加这个字符前的位置
+func (l *Line) DeleteSearchCharacter() {
+	if !l.mode.Is(linemode.IncrementalSearch) {
+		return
+	}
+	state := l.isearchState
+	if len(state.text) == 0 {
+		return
+	}
+	state.text = state.text[:len(state.text)-1]
+	length := len(state.positionStack)
+	if length > 0 {
+		top := state.positionStack[length-1]
+		state.positionStack = state.positionStack[:length-1]
+		l.gotoWorkingLine(top.workingIndex, top.cursorPosition)
+		state.failed = top.failed
+	} else {
+		//    沿用上一次的搜索文本时没有保存位置，从搜索开始的位置重新搜索
+		l.gotoWorkingLine(state.originalWorkingIndex, state.originalCursorPosition)
+		state.failed = false
+		if len(state.text) > 0 {
+			l.searchText(true)
+		}
+	}
+}
+
+// AcceptSearch 结束搜索，保留找到的输入
+func (l *Line) AcceptSearch() {
+	if l.mode.Is(linemode.IncrementalSearch) {
+		if len(l.isearchState.text) > 0 {
+			l.lastSearchText = l.isearchState.text
+		}
+		l.mode = linemode.Normal
+		l.isearchState = nil
+	}
+}
+
+// CancelSearch 取消搜索，恢复到搜索开始时的输入（Ctrl-G）
+func (l *Line) CancelSearch() {
+	if l.mode.Is(linemode.IncrementalSearch) {
+		state := l.isearchState
+		l.gotoWorkingLine(state.originalWorkingIndex, state.originalCursorPosition)
+		l.AcceptSearch()
+	}
+}
+
+// searchText 从当前输入的光标位置开始，按搜索方向查找搜索文本
+// includeCurrent 表示光标处的匹配是否算在内
+func (l *Line) searchText(includeCurrent bool) {
+	state := l.isearchState
+	step := 1
+	if state.backward {
+		step = -1
+	}
+	position := l.cursorPosition
+	if !includeCurrent {
+		position += step
+	}
+	for index := l.workingIndex; 0 <= index && index < len(l.workingLines); index += step {
+		runes := []rune(l.workingLines[index])
+		if index != l.workingIndex {
+			//    其他输入从头（或者从尾）开始找
+			if state.backward {
+				position = len(runes)
+			} else {
+				position = 0
+			}
+		}
+		pos := searchRunes(runes, state.text, position, state.backward)
+		if pos != -1 {
+			state.failed = false
+			l.gotoWorkingLine(index, pos)
+			return
+		}
+	}
+	state.failed = true
+}
+
+// gotoWorkingLine 切换到第 index 个输入，并将光标移动到 position
+func (l *Line) gotoWorkingLine(index int, position int) {
+	l.workingIndex = index
+	l.buffer = []rune(l.workingLines[index])
+	l.SetCursorPosition(position)
+}
+
+// HasSelection 返回 true 表示有选中的文本
+func (l *Line) HasSelection() bool {
+	return l.selection.start != -1 && l.selection.start != l.selection.end
+}
+
+// selectionRange 返回选中区域 [start, end)
+func (l *Line) selectionRange() (int, int) {
+	start, end := l.selection.start, l.selection.end
+	if start > end {
+		start, end = end, start
+	}
+	return minInt(start, len(l.buffer)), minInt(end, len(l.buffer))
+}
+
+// SelectedText 返回选中的文本
+func (l *Line) SelectedText() string {
+	if !l.HasSelection() {
+		return ""
+	}
+	start, end := l.selectionRange()
+	return string(l.buffer[start:end])
+}
+
+// ClearSelection 取消选中
+func (l *Line) ClearSelection() {
+	l.selection = _LineArea{-1, -1}
+}
+
+// DeleteSelection 删除选中的文本，光标移动到删除的位置，返回删除的文本
+func (l *Line) DeleteSelection() string {
+	if !l.HasSelection() {
+		return ""
+	}
+	start, end := l.selectionRange()
+	deleted := l.removeRunes(start, end-start)
+	l.SetCursorPosition(start)
+	l.selection = _LineArea{-1, -1}
+	l.cancelSelection = true
+	return string(deleted)
+}
+
+// ReplaceSelection 将选中的文本替换为 text ，没有选中时在光标处插入
+func (l *Line) ReplaceSelection(text []rune) {
+	l.DeleteSelection()
+	l.insertText(text, true)
+}
+
+// CopySelection 复制选中的文本到剪贴板，复制后取消选中
+func (l *Line) CopySelection() {
+	text := l.SelectedText()
+	if text == "" {
+		return
+	}
+	if err := l.clipboard.Write(text); err != nil {
+		DebugLog("clipboard write error: %v", err)
+		return
+	}
+	l.ClearSelection()
+	l.cancelSelection = true
+}
+
+// CutSelection 剪切选中的文本到剪贴板
+func (l *Line) CutSelection() {
+	text := l.SelectedText()
+	if text == "" {
+		return
+	}
+	if err := l.clipboard.Write(text); err != nil {
+		DebugLog("clipboard write error: %v", err)
+		return
+	}
+	l.DeleteSelection()
+}
+
+// PasteClipboard 粘贴剪贴板中的文本，有选中时替换选中的文本
+func (l *Line) PasteClipboard() {
+	text, err := l.clipboard.Read()
+	if err != nil {
+		DebugLog("clipboard read error: %v", err)
+		return
+	}
+	if text == "" {
+		return
+	}
+	l.PasteText([]rune(text))
+}
+
+// ExtendSelection 移动光标，并将选中区域扩展到移动后的光标处
+//
+//	没有选中时从移动前的光标处开始选中，比如 line.ExtendSelection((*Line).CursorLeft) 跟 Shift-Left 一样
+func (l *Line) ExtendSelection(move func(l *Line)) {
+	if l.selection.start == -1 {
+		l.selection = _LineArea{l.cursorPosition, l.cursorPosition}
+	}
+	move(l)
+	l.selection.end = l.cursorPosition
+}
+
+// SelectWord 选中光标所在的单词（以空白分隔），光标移动到单词末尾
+func (l *Line) SelectWord() {
+	start := l.cursorPosition
+	for start > 0 && !unicode.IsSpace(l.buffer[start-1]) {
+		start--
+	}
+	end := l.cursorPosition
+	for end < len(l.buffer) && !unicode.IsSpace(l.buffer[end]) {
+		end++
+	}
+	if start == end {
+		return
+	}
+	l.selection = _LineArea{start, end}
+	l.SetCursorPosition(end)
+}
+
+// SelectLine 选中光标所在行（不包括换行符），光标移动到行尾
+func (l *Line) SelectLine() {
+	start := l.cursorPosition
+	for start > 0 && l.buffer[start-1] != '\n' {
+		start--
+	}
+	end := l.cursorPosition
+	for end < len(l.buffer) && l.buffer[end] != '\n' {
+		end++
+	}
+	l.selection = _LineArea{start, end}
+	l.SetCursorPosition(end)
+}
+
+// SelectAll 选中所有文本，光标移动到末尾
+func (l *Line) SelectAll() {
+	l.selection = _LineArea{0, len(l.buffer)}
+	l.SetCursorPosition(len(l.buffer))
+}
+
 func (l *Line) MouseDown(info *MouseInfoOfInput) {
 	location := info.location
 	if location.Row == -1 || location.Col == -1 {
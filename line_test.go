@@ -1,10 +1,44 @@
 package startprompt
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yetsing/startprompt/enums/linemode"
+)
 
 func newTestLine() *Line {
-	return newLine(
-		newBaseCode, newBasePrompt, NewMemHistory(), NewNoopCallbacks(), false)
+	return newLine(newBaseCode, NewMemHistory(), false)
+}
+
+type _TestCompleteCode struct {
+	_BaseCode
+}
+
+func newTestCompleteCode(document *Document) Code {
+	return &_TestCompleteCode{_BaseCode{document: document}}
+}
+
+func (c *_TestCompleteCode) GetCompletions() []*Completion {
+	return []*Completion{
+		{Display: "apple", Suffix: "pple"},
+		{Display: "avocado", Suffix: "vocado"},
+	}
+}
+
+// _TestAsyncCompleteCode 补全由测试通过 channel 逐个发送
+type _TestAsyncCompleteCode struct {
+	_BaseCode
+	ch chan *Completion
+	//    保存取消信号，检查过时的请求是否被取消
+	ctxs *[]context.Context
+}
+
+func (c *_TestAsyncCompleteCode) GetCompletionsAsync(ctx context.Context) <-chan *Completion {
+	*c.ctxs = append(*c.ctxs, ctx)
+	return c.ch
 }
 
 func TestLineInitial(t *testing.T) {
@@ -112,3 +146,396 @@ func TestLine_SwapCharactersBeforeCursor(t *testing.T) {
 	cli.SwapCharactersBeforeCursor()
 	testStringEqual(t, "hello wrold", cli.text())
 }
+
+func TestLine_IncrementalSearch(t *testing.T) {
+	history := NewMemHistory()
+	history.Append("echo hello")
+	history.Append("ls -l")
+	history.Append("echo world")
+	cli := newLine(newBaseCode, history, false)
+
+	cli.ReverseSearch()
+	cli.InsertSearchText([]rune("echo"))
+	testStringEqual(t, "echo world", cli.text())
+	testIntEqual(t, 0, cli.GetCursorPosition())
+
+	//    再次按下 Ctrl-R 跳到更早的匹配
+	cli.ReverseSearch()
+	testStringEqual(t, "echo hello", cli.text())
+	testBoolEqual(t, false, cli.isearchState.failed)
+
+	//    没有更早的匹配，保持不变
+	cli.ReverseSearch()
+	testStringEqual(t, "echo hello", cli.text())
+	testBoolEqual(t, true, cli.isearchState.failed)
+
+	cli.ForwardSearch()
+	testStringEqual(t, "echo world", cli.text())
+
+	//    删除搜索文本会回到追加这个字符前的位置
+	cli.InsertSearchText([]rune("x"))
+	testBoolEqual(t, true, cli.isearchState.failed)
+	cli.DeleteSearchCharacter()
+	testStringEqual(t, "echo", string(cli.isearchState.text))
+	testStringEqual(t, "echo world", cli.text())
+	testBoolEqual(t, false, cli.isearchState.failed)
+
+	//    取消搜索恢复到搜索开始时的输入
+	cli.CancelSearch()
+	testStringEqual(t, "", cli.text())
+	testBoolEqual(t, true, cli.mode.Is(linemode.Normal))
+
+	cli.ReverseSearch()
+	cli.InsertSearchText([]rune("-"))
+	testStringEqual(t, "ls -l", cli.text())
+	testIntEqual(t, 3, cli.GetCursorPosition())
+	cli.AcceptSearch()
+	testStringEqual(t, "ls -l", cli.text())
+	testBoolEqual(t, true, cli.mode.Is(linemode.Normal))
+
+	//    搜索文本为空时，沿用上一次的搜索文本
+	cli.ReverseSearch()
+	cli.ReverseSearch()
+	testStringEqual(t, "-", string(cli.isearchState.text))
+	testStringEqual(t, "ls -l", cli.text())
+}
+
+func TestLine_Selection(t *testing.T) {
+	line := newTestLine()
+	line.InsertText([]rune("one two\nthree"), true)
+	line.ExtendSelection((*Line).CursorLeft)
+	line.ExtendSelection((*Line).CursorWordBack)
+	testStringEqual(t, "three", line.SelectedText())
+	//    向右移动缩小选中区域
+	line.ExtendSelection((*Line).CursorRight)
+	testStringEqual(t, "hree", line.SelectedText())
+	line.ReplaceSelection([]rune("X"))
+	testStringEqual(t, "one two\ntX", line.text())
+	testIntEqual(t, len("one two\ntX"), line.GetCursorPosition())
+	testStringEqual(t, "", line.SelectedText())
+
+	line.CursorUp()
+	line.CursorToEndOfLine()
+	line.SelectWord()
+	testStringEqual(t, "two", line.SelectedText())
+	line.InsertText([]rune("2"), true)
+	testStringEqual(t, "one 2\ntX", line.text())
+
+	line.SelectLine()
+	testStringEqual(t, "one 2", line.SelectedText())
+	testStringEqual(t, "one 2", line.DeleteSelection())
+	testStringEqual(t, "\ntX", line.text())
+	testIntEqual(t, 0, line.GetCursorPosition())
+
+	line.SelectAll()
+	testStringEqual(t, "\ntX", line.SelectedText())
+	line.ClearSelection()
+	if line.HasSelection() {
+		t.Errorf("selection should be cleared")
+	}
+}
+
+func TestLine_Clipboard(t *testing.T) {
+	line := newTestLine()
+	line.InsertText([]rune("one two"), true)
+	line.SelectWord()
+	line.CopySelection()
+	testStringEqual(t, "one two", line.text())
+	if line.HasSelection() {
+		t.Errorf("selection should be cleared after copy")
+	}
+	line.CursorToStartOfLine(false)
+	line.PasteClipboard()
+	testStringEqual(t, "twoone two", line.text())
+
+	line.SelectAll()
+	line.CutSelection()
+	testStringEqual(t, "", line.text())
+	text, _ := line.clipboard.Read()
+	testStringEqual(t, "twoone two", text)
+
+	var buf strings.Builder
+	osc52 := NewOSC52Clipboard(&buf)
+	line.clipboard = osc52
+	line.InsertText([]rune("hi"), true)
+	line.SelectAll()
+	line.CopySelection()
+	testStringEqual(t, "\x1b]52;c;aGk=\a", buf.String())
+	text, _ = osc52.Read()
+	testStringEqual(t, "hi", text)
+}
+
+func TestLine_SetText(t *testing.T) {
+	line := newTestLine()
+	line.InsertText([]rune("one"), true)
+	line.SetText("hello world", 5)
+	testStringEqual(t, "hello world", line.text())
+	testIntEqual(t, 5, line.GetCursorPosition())
+	//    光标不会超出文本
+	line.SetText("hi", 10)
+	testIntEqual(t, 2, line.GetCursorPosition())
+	line.Undo()
+	testStringEqual(t, "hello world", line.text())
+
+	//    替换文本时退出补全，旧的补全不会用在新文本上
+	line = newLine(newTestCompleteCode, NewMemHistory(), false)
+	line.InsertText([]rune("a"), true)
+	line.StartComplete(false)
+	testBoolEqual(t, true, line.mode.Is(linemode.Complete))
+	line.SetText("git status", 3)
+	testBoolEqual(t, true, line.mode.Is(linemode.Normal))
+	line.CompleteNext(1)
+	testStringEqual(t, "gitpple status", line.text())
+}
+
+type _TestReplaceCompleteCode struct {
+	_BaseCode
+}
+
+func (c *_TestReplaceCompleteCode) GetCompletions() []*Completion {
+	return []*Completion{
+		{Display: "Apple", Text: "Apple", StartPosition: -2},
+		{Display: "apricot", Text: "apricot", StartPosition: -2},
+		{Display: "ap!", Suffix: "!"},
+	}
+}
+
+func TestLine_CompleteReplacement(t *testing.T) {
+	line := newLine(func(document *Document) Code {
+		return &_TestReplaceCompleteCode{_BaseCode{document: document}}
+	}, NewMemHistory(), false)
+	line.InsertText([]rune("eat ap"), true)
+	line.CompleteNext(1)
+	testStringEqual(t, "eat Apple", line.text())
+	testIntEqual(t, len("eat Apple"), line.GetCursorPosition())
+	//    切换补全时恢复原来的文本再替换
+	line.CompleteNext(1)
+	testStringEqual(t, "eat apricot", line.text())
+	line.CompleteNext(1)
+	testStringEqual(t, "eat ap!", line.text())
+	line.CompletePrevious(2)
+	testStringEqual(t, "eat Apple", line.text())
+	//    取消补全恢复原来的文本
+	line.CancelComplete()
+	testStringEqual(t, "eat ap", line.text())
+	testIntEqual(t, len("eat ap"), line.GetCursorPosition())
+
+	line.CompleteNext(1)
+	line.CompleteNext(1)
+	line.AcceptComplete()
+	testStringEqual(t, "eat apricot", line.text())
+	if line.mode.Is(linemode.Complete) {
+		t.Errorf("should leave complete mode")
+	}
+
+	//    StartPosition 大于 0 时在光标处插入
+	line = newLine(WithCompleter(nil, CompleterFunc(func(document *Document) []*Completion {
+		return []*Completion{{Display: "x", Text: "x", StartPosition: 2}, {Display: "y", Text: "y"}}
+	})), NewMemHistory(), false)
+	line.InsertText([]rune("ab"), true)
+	line.CompleteNext(1)
+	testStringEqual(t, "abx", line.text())
+}
+
+func TestLine_AsyncComplete(t *testing.T) {
+	ch := make(chan *Completion)
+	var ctxs []context.Context
+	line := newLine(func(document *Document) Code {
+		return &_TestAsyncCompleteCode{_BaseCode{document: document}, ch, &ctxs}
+	}, NewMemHistory(), false)
+	invokes := make(chan func(line *Line), 16)
+	line.invoke = func(fn func(line *Line)) {
+		invokes <- fn
+	}
+
+	line.InsertText([]rune("a"), true)
+	line.CompleteNext(1)
+	if !line.mode.Is(linemode.Complete) || !line.completeState.loading {
+		t.Fatalf("expected loading completion state")
+	}
+	//    补全还没有到来时选择补全不会有任何效果
+	line.CompleteNext(1)
+	line.CompletePrevious(1)
+	testStringEqual(t, "a", line.text())
+
+	ch <- &Completion{Display: "apple", Suffix: "pple"}
+	for line.completeState == nil || len(line.completeState.currentCompletions) == 0 {
+		(<-invokes)(line)
+	}
+	//    第一个补全到来时选中
+	testStringEqual(t, "apple", line.text())
+	close(ch)
+	for line.asyncComplete != nil {
+		(<-invokes)(line)
+	}
+	if line.completeState.loading {
+		t.Errorf("completion should finish loading")
+	}
+	line.AcceptComplete()
+
+	//    开始新的补全时，取消之前的请求
+	ch = make(chan *Completion)
+	line.StartComplete(false)
+	line.StartComplete(false)
+	if len(ctxs) != 3 || ctxs[1].Err() == nil || ctxs[2].Err() != nil {
+		t.Errorf("stale request should be cancelled")
+	}
+	line.CancelComplete()
+	if ctxs[2].Err() == nil {
+		t.Errorf("request should be cancelled after CancelComplete")
+	}
+	close(ch)
+}
+
+func TestLine_AsyncCompleteTextChanged(t *testing.T) {
+	ch := make(chan *Completion)
+	defer close(ch)
+	var ctxs []context.Context
+	line := newLine(func(document *Document) Code {
+		return &_TestAsyncCompleteCode{_BaseCode{document: document}, ch, &ctxs}
+	}, NewMemHistory(), false)
+	line.invoke = func(fn func(line *Line)) {}
+
+	//    补全还在加载时文本被修改（比如通过 Invoke ），之后到来的补全会被忽略
+	line.InsertText([]rune("a"), true)
+	line.StartComplete(true)
+	request := line.asyncComplete
+	line.SetText("zzz", 3)
+	line.addAsyncCompletions(request, []*Completion{{Display: "apple", Suffix: "pple"}})
+	line.finishAsyncComplete(request)
+	testStringEqual(t, "zzz", line.text())
+	testBoolEqual(t, true, line.mode.Is(linemode.Normal))
+	if ctxs[0].Err() == nil {
+		t.Errorf("request should be cancelled after text changed")
+	}
+
+	line.StartComplete(true)
+	request = line.asyncComplete
+	line.InsertText([]rune("!"), true)
+	line.addAsyncCompletions(request, []*Completion{{Display: "apple", Suffix: "pple"}})
+	testStringEqual(t, "zzz!", line.text())
+	testBoolEqual(t, true, line.mode.Is(linemode.Normal))
+}
+
+func TestLine_AsyncCompleteCancelStopsGoroutine(t *testing.T) {
+	ch := make(chan *Completion)
+	defer close(ch)
+	var ctxs []context.Context
+	line := newLine(func(document *Document) Code {
+		return &_TestAsyncCompleteCode{_BaseCode{document: document}, ch, &ctxs}
+	}, NewMemHistory(), false)
+	line.invoke = func(fn func(line *Line)) {}
+
+	//    补全取消后，即使 channel 没有关闭，后台协程也会退出，不再接收补全
+	line.InsertText([]rune("a"), true)
+	line.StartComplete(true)
+	line.CancelComplete()
+	time.Sleep(asyncCompleteSpinnerInterval / 10)
+	select {
+	case ch <- &Completion{Display: "apple", Suffix: "pple"}:
+		t.Errorf("async completion goroutine should exit after cancel")
+	case <-time.After(2 * asyncCompleteSpinnerInterval):
+	}
+}
+
+func TestLine_KillRing(t *testing.T) {
+	line := newTestLine()
+	line.InsertText([]rune("one two three"), true)
+	//    连续向前删除单词，合并成一个条目
+	line.KillWordBeforeCursor()
+	line.KillWordBeforeCursor()
+	testStringEqual(t, "one ", line.text())
+	line.Yank()
+	testStringEqual(t, "one two three", line.text())
+
+	//    中间有其他操作，不再合并
+	line.Home()
+	line.KillUntilEndOfLine()
+	line.InsertText([]rune("x "), true)
+	line.KillFromStartOfLine()
+	testStringEqual(t, "", line.text())
+
+	line.Yank()
+	testStringEqual(t, "x ", line.text())
+	line.YankPop()
+	testStringEqual(t, "one two three", line.text())
+	testIntEqual(t, len("one two three"), line.GetCursorPosition())
+	line.YankPop()
+	testStringEqual(t, "two three", line.text())
+	//    循环回到最新的条目
+	line.YankPop()
+	testStringEqual(t, "x ", line.text())
+
+	//    上一次操作不是粘贴时 YankPop 不生效
+	line.CursorLeft()
+	line.YankPop()
+	testStringEqual(t, "x ", line.text())
+}
+
+func TestLine_UndoRedo(t *testing.T) {
+	line := newTestLine()
+	line.SaveToUndoStack()
+	line.InsertText([]rune("one"), true)
+	line.SaveToUndoStack()
+	line.InsertText([]rune(" two"), true)
+
+	line.Undo()
+	testStringEqual(t, "one", line.text())
+	line.Undo()
+	testStringEqual(t, "", line.text())
+	//    没有可以撤销的记录
+	line.Undo()
+	testStringEqual(t, "", line.text())
+
+	line.Redo()
+	testStringEqual(t, "one", line.text())
+	testIntEqual(t, 3, line.GetCursorPosition())
+	line.Redo()
+	testStringEqual(t, "one two", line.text())
+	line.Redo()
+	testStringEqual(t, "one two", line.text())
+
+	//    有新的修改后，不能再恢复
+	line.Undo()
+	line.SaveToUndoStack()
+	line.InsertText([]rune("!"), true)
+	line.Redo()
+	testStringEqual(t, "one!", line.text())
+}
+
+func TestLine_UndoRedoCompletion(t *testing.T) {
+	line := newLine(newTestCompleteCode, NewMemHistory(), false)
+	line.InsertText([]rune("a"), true)
+	line.SaveToUndoStack()
+	line.CompleteNext(1)
+	line.SaveToUndoStack()
+	line.CompleteNext(1)
+	completed := line.text()
+
+	line.Undo()
+	testIntEqual(t, 0, line.completeState.completeIndex)
+	line.Undo()
+	testStringEqual(t, "a", line.text())
+	if !line.mode.Is(linemode.Normal) {
+		t.Errorf("line mode want=Normal, but got=%s", line.mode)
+	}
+
+	line.Redo()
+	line.Redo()
+	testStringEqual(t, completed, line.text())
+	if !line.mode.Is(linemode.Complete) {
+		t.Fatalf("line mode want=Complete, but got=%s", line.mode)
+	}
+	testIntEqual(t, 1, line.completeState.completeIndex)
+}
+
+func TestLine_PasteText(t *testing.T) {
+	cli := newTestLine()
+	cli.InsertText([]rune("hello world"), true)
+	//    从右往左选中 "hello" ，光标在选中区域的开头
+	cli.selection = _LineArea{start: 5, end: 0}
+	cli.SetCursorPosition(0)
+	cli.PasteText([]rune("bye"))
+	testStringEqual(t, "bye world", cli.text())
+	testIntEqual(t, 3, cli.GetCursorPosition())
+}
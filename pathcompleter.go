@@ -0,0 +1,188 @@
+package startprompt
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+文件路径补全，可以在任意 Code 的 GetCompletions 中使用，比如
+
+	func (c *MyCode) GetCompletions() []*startprompt.Completion {
+		return c.pathCompleter.GetCompletions(c.document)
+	}
+*/
+
+// PathCompleter 补全光标前的文件路径
+//
+//	路径从光标往前找到第一个没有转义的空白字符为止，比如 "cat my\ fi" 中的路径是 "my fi"
+//	支持 ~ 展开为用户目录，补全中的空格等特殊字符会用 \ 转义
+type PathCompleter struct {
+	// Dir 相对路径的基准目录，为空时使用当前工作目录
+	Dir string
+	// ShowHidden 为 true 时总是显示以 . 开头的隐藏文件
+	// 为 false 时只有输入的文件名以 . 开头才显示
+	ShowHidden bool
+	// OnlyDirectories 为 true 时只补全目录
+	OnlyDirectories bool
+}
+
+func NewPathCompleter() *PathCompleter {
+	return &PathCompleter{}
+}
+
+// GetCompletions 返回光标前路径所在目录中匹配的文件
+//
+//	目录的补全以 / 结尾， DisplayMeta 是文件类型（见 pathFileType）
+func (pc *PathCompleter) GetCompletions(document *Document) []*Completion {
+	raw := pathBeforeCursor(document.TextBeforeCursor())
+	if raw == "~" {
+		return []*Completion{
+			{Display: "~/", Text: "~/", StartPosition: -1, DisplayMeta: "dir"},
+		}
+	}
+	//    rawDir 保持用户的输入（包括 ~ 和转义），补全时只替换文件名部分
+	rawDir := ""
+	if index := strings.LastIndex(raw, "/"); index != -1 {
+		rawDir = raw[:index+1]
+	}
+	path := unescapePath(raw)
+	dir, prefix := "", path
+	if index := strings.LastIndex(path, "/"); index != -1 {
+		dir, prefix = path[:index+1], path[index+1:]
+	}
+
+	listDir := expandUser(dir)
+	if listDir == "" {
+		listDir = "."
+	}
+	if !filepath.IsAbs(listDir) && pc.Dir != "" {
+		listDir = filepath.Join(pc.Dir, listDir)
+	}
+	entries, err := os.ReadDir(listDir)
+	if err != nil {
+		return nil
+	}
+
+	var completions []*Completion
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !pc.ShowHidden && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		fileType := pathFileType(listDir, entry)
+		isDir := fileType == "dir" || fileType == "link/dir"
+		if pc.OnlyDirectories && !isDir {
+			continue
+		}
+		display, text := name, rawDir+escapePath(name)
+		if isDir {
+			display += "/"
+			text += "/"
+		}
+		positions := make([]int, len([]rune(prefix)))
+		for i := range positions {
+			positions[i] = i
+		}
+		completions = append(completions, &Completion{
+			Display:        display,
+			Text:           text,
+			StartPosition:  -len([]rune(raw)),
+			DisplayMeta:    fileType,
+			MatchPositions: positions,
+		})
+	}
+	return completions
+}
+
+// pathBeforeCursor 返回文本末尾的路径（保留转义），遇到没有转义的空白字符为止
+func pathBeforeCursor(text string) string {
+	runes := []rune(text)
+	start := len(runes)
+	for start > 0 {
+		r := runes[start-1]
+		if r == ' ' || r == '\t' || r == '\n' {
+			//    前面有奇数个 \ 说明空白字符被转义了
+			backslashes := 0
+			for i := start - 2; i >= 0 && runes[i] == '\\'; i-- {
+				backslashes++
+			}
+			if backslashes%2 == 0 {
+				break
+			}
+		}
+		start--
+	}
+	return string(runes[start:])
+}
+
+// pathSpecialChars 路径中需要转义的字符
+const pathSpecialChars = " \t\\\"'"
+
+// escapePath 用 \ 转义路径中的特殊字符，比如 "my file" 转义为 "my\ file"
+func escapePath(path string) string {
+	var builder strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(pathSpecialChars, r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// unescapePath escapePath 的逆操作
+func unescapePath(path string) string {
+	var builder strings.Builder
+	escaped := false
+	for _, r := range path {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// expandUser 将开头的 ~ 展开为用户目录，只支持 "~" 和 "~/" ，不支持 "~user"
+func expandUser(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
+
+// pathFileType 返回文件类型，有 "dir" "file" "exec" "link/dir" "link/file" 和 "link"（链接失效）
+func pathFileType(dir string, entry fs.DirEntry) string {
+	mode := entry.Type()
+	if mode&fs.ModeSymlink != 0 {
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "link"
+		}
+		if info.IsDir() {
+			return "link/dir"
+		}
+		return "link/file"
+	}
+	if mode.IsDir() {
+		return "dir"
+	}
+	if mode.IsRegular() {
+		if info, err := entry.Info(); err == nil && info.Mode().Perm()&0111 != 0 {
+			return "exec"
+		}
+	}
+	return "file"
+}
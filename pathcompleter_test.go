@@ -0,0 +1,51 @@
+package startprompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathCompleter(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"apple", "my file.txt", ".hidden", "sub/banana"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := &PathCompleter{Dir: dir}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ls ", "[apple|apple|0|file my file.txt|my\\ file.txt|0|file run.sh|run.sh|0|exec sub/|sub/|0|dir]"},
+		{"ls a", "[apple|apple|-1|file]"},
+		//    输入 . 开头时显示隐藏文件
+		{"ls .h", "[.hidden|.hidden|-2|file]"},
+		//    转义的空格
+		{"ls my\\ f", "[my file.txt|my\\ file.txt|-5|file]"},
+		{"ls sub/b", "[banana|sub/banana|-5|file]"},
+		{"ls nothing/", "[]"},
+	}
+	for _, tt := range tests {
+		testStringEqual(t, tt.want, completeText(pc, tt.text))
+	}
+
+	pc = &PathCompleter{Dir: dir, ShowHidden: true, OnlyDirectories: true}
+	testStringEqual(t, "[sub/|sub/|0|dir]", completeText(pc, ""))
+	pc = &PathCompleter{Dir: dir, ShowHidden: true}
+	testIntEqual(t, 5, len(pc.GetCompletions(NewDocument("", 0))))
+
+	t.Setenv("HOME", dir)
+	pc = NewPathCompleter()
+	testStringEqual(t, "[~/|~/|-1|dir]", completeText(pc, "~"))
+	testStringEqual(t, "[sub/|~/sub/|-3|dir]", completeText(pc, "~/s"))
+}
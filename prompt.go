@@ -5,6 +5,7 @@ import (
 	"unicode"
 
 	"github.com/mattn/go-runewidth"
+	"github.com/yetsing/startprompt/enums/linemode"
 	"github.com/yetsing/startprompt/token"
 )
 
@@ -17,6 +18,28 @@ type Prompt interface {
 	GetSecondLinePrefix() []token.Token
 }
 
+// ModePrompt 可选接口， Prompt 实现后可以根据编辑模式（比如 vi 的 normal insert visual 模式）显示不同的提示符
+type ModePrompt interface {
+	// GetModePrompt 获取指定编辑模式下的提示符，只在编辑模式不为空时调用，
+	// 实现后不会再加上默认的模式标识（见 modeIndicators）
+	GetModePrompt(mode linemode.LineMode) []token.Token
+}
+
+// RightPrompt 可选接口， Prompt 实现后会在输入第一行的右侧显示提示符（类似 zsh 的 RPROMPT ），比如 git 分支、时间
+//
+//	输入跟右侧提示符重叠时不显示，确定输入后也不再显示
+type RightPrompt interface {
+	// GetRightPrompt 获取右侧提示符
+	GetRightPrompt() []token.Token
+}
+
+// TransientPrompt 可选接口， Prompt 实现后确定输入时会用简短的提示符（比如 "$ "）重新显示输入，
+// 多行提示符和后续行前缀不会留在终端的历史输出中
+type TransientPrompt interface {
+	// GetTransientPrompt 获取确定输入后的提示符
+	GetTransientPrompt() []token.Token
+}
+
 type BasePrompt struct {
 }
 
@@ -33,6 +56,13 @@ func (b *BasePrompt) GetPrompt() []token.Token {
 	return []token.Token{tk}
 }
 
+// modeIndicators 编辑模式的标识，没有实现 ModePrompt 的提示符会在前面加上标识，比如 vi normal 模式下为 "[N] > "
+var modeIndicators = map[linemode.LineMode]string{
+	linemode.ViNormal: "[N] ",
+	linemode.ViInsert: "[I] ",
+	linemode.ViVisual: "[V] ",
+}
+
 func (b *BasePrompt) GetSecondLinePrefix() []token.Token {
 	// 拿到默认提示符宽度
 	var sb strings.Builder
@@ -45,12 +75,12 @@ func (b *BasePrompt) GetSecondLinePrefix() []token.Token {
 	// 输出类似这样的 "...  " ，宽度跟默认提示符一样
 	return []token.Token{
 		{
-			token.PromptSecondLinePrefix,
-			repeatByte('.', width),
+			Type:    token.PromptSecondLinePrefix,
+			Literal: repeatByte('.', width),
 		},
 		{
-			token.PromptSecondLinePrefix,
-			repeatByte(' ', spaces),
+			Type:    token.PromptSecondLinePrefix,
+			Literal: repeatByte(' ', spaces),
 		},
 	}
 }
@@ -1,25 +1,99 @@
 package startprompt
 
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/yetsing/startprompt/enums/linemode"
+	"github.com/yetsing/startprompt/token"
+)
+
 type RenderContext struct {
-	completeState   *cCompletionState
-	document        *Document
-	code            Code
-	highlights      []section
+	completeState *cCompletionState
+	isearchState  *cIncrementalSearchState
+	document      *Document
+	code          Code
+	highlights    []section
+	//    增量搜索匹配的区域
+	searchMatches []section
+	//    选中的区域
+	selections      []section
 	cancelSelection bool
+	//    编辑模式，比如 vi 的 normal insert visual 模式
+	editMode linemode.LineMode
+	//    组合键已经按下的前缀
+	pendingKeys string
+	//    输入检查错误
+	validationError *ValidationError
+	//    补全的展示方式
+	completionDisplay cCompletionDisplayOption
+	//    询问是否列出全部补全时为补全的数量，否则为 0
+	completionQuery int
 }
 
 func newRenderContext(
 	code Code,
 	completeState *cCompletionState,
+	isearchState *cIncrementalSearchState,
 	document *Document,
 	highlights []section,
+	searchMatches []section,
+	selections []section,
 	cancelSelection bool,
+	editMode linemode.LineMode,
+	pendingKeys string,
+	validationError *ValidationError,
+	completionDisplay cCompletionDisplayOption,
+	completionQuery int,
 ) *RenderContext {
 	return &RenderContext{
 		code:            code,
 		completeState:   completeState,
+		isearchState:    isearchState,
 		document:        document,
 		highlights:      highlights,
+		searchMatches:   searchMatches,
+		selections:      selections,
 		cancelSelection: cancelSelection,
+		editMode:        editMode,
+		pendingKeys:     pendingKeys,
+		validationError: validationError,
+
+		completionDisplay: completionDisplay,
+		completionQuery:   completionQuery,
+	}
+}
+
+// getPrompt 返回提示符，增量搜索时返回搜索提示符，
+// 编辑模式不为空时在提示符前面加上模式标识（提示符实现了 ModePrompt 时由提示符决定）
+func (rc *RenderContext) getPrompt(prompt Prompt) []token.Token {
+	if rc.isearchState != nil {
+		return rc.isearchState.getPrompt()
+	}
+	if modePrompt, ok := prompt.(ModePrompt); ok && rc.editMode != "" {
+		return modePrompt.GetModePrompt(rc.editMode)
+	}
+	indicator := rc.getModeIndicator(prompt)
+	if indicator == "" {
+		return prompt.GetPrompt()
+	}
+	return append([]token.Token{token.NewToken(token.PromptMode, indicator)}, prompt.GetPrompt()...)
+}
+
+// getSecondLinePrefix 返回后续行前缀，有模式标识时在前面补上同样宽度的空格，跟第一行的输入对齐
+func (rc *RenderContext) getSecondLinePrefix(prompt Prompt) []token.Token {
+	indicator := rc.getModeIndicator(prompt)
+	if indicator == "" {
+		return prompt.GetSecondLinePrefix()
+	}
+	spaces := token.NewToken(token.PromptSecondLinePrefix, strings.Repeat(" ", runewidth.StringWidth(indicator)))
+	return append([]token.Token{spaces}, prompt.GetSecondLinePrefix()...)
+}
+
+// getModeIndicator 返回需要加在提示符前面的模式标识，没有时返回空字符串
+func (rc *RenderContext) getModeIndicator(prompt Prompt) string {
+	if _, ok := prompt.(ModePrompt); ok {
+		return ""
 	}
+	return modeIndicators[rc.editMode]
 }
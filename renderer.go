@@ -3,7 +3,7 @@ package startprompt
 import (
 	"bufio"
 	"bytes"
-	"os"
+	"io"
 
 	"github.com/mattn/go-runewidth"
 
@@ -11,20 +11,32 @@ import (
 	"github.com/yetsing/startprompt/token"
 )
 
-func newRenderer(schema Schema, promptFactory PromptFactory) *Renderer {
+func newRenderer(
+	writer io.Writer,
+	sizeFunc func() (int, int),
+	schema Schema,
+	promptFactory PromptFactory,
+	toolbar func() []token.Token,
+) *Renderer {
 	return &Renderer{
-		writer:        bufio.NewWriter(os.Stdout),
+		writer:        bufio.NewWriter(writer),
+		sizeFunc:      sizeFunc,
 		schema:        schema,
 		promptFactory: promptFactory,
+		toolbar:       toolbar,
 	}
 }
 
 type Renderer struct {
 	writer *bufio.Writer
-	schema Schema
+	//    返回终端窗口的宽度和高度
+	sizeFunc func() (int, int)
+	schema   Schema
 	//    光标在输入文本中的坐标（这是一个相对于输入文本左上角的坐标）
 	cursorCoordinate Coordinate
 	promptFactory    PromptFactory
+	//    返回底部工具栏的内容
+	toolbar func() []token.Token
 }
 
 type _Size struct {
@@ -33,24 +45,30 @@ type _Size struct {
 }
 
 func (r *Renderer) getSize() _Size {
-	width, height := getSize(int(os.Stdin.Fd()))
+	width, height := r.sizeFunc()
 	return _Size{
 		width:  width,
 		height: height,
 	}
 }
 
-func (r *Renderer) getNewScreen(renderContext *RenderContext) *Screen {
+// getNewScreen accepted 表示用户已经确定（或者放弃）输入
+func (r *Renderer) getNewScreen(renderContext *RenderContext, accepted bool) *Screen {
 	screen := NewScreen(r.schema, r.getSize())
 
 	//    写入提示符
 	prompt := r.promptFactory(renderContext.code)
-	prompts := prompt.GetPrompt()
-	screen.WriteTokens(prompts, false)
-	//    设置后续行前缀函数
-	screen.setSecondLinePrefix(func() []token.Token {
-		return prompt.GetSecondLinePrefix()
-	})
+	if transientPrompt, ok := prompt.(TransientPrompt); ok && accepted {
+		//    确定输入后改用简短的提示符，后续行不加前缀
+		screen.WriteTokens(transientPrompt.GetTransientPrompt(), false)
+	} else {
+		prompts := renderContext.getPrompt(prompt)
+		screen.WriteTokens(prompts, false)
+		//    设置后续行前缀函数
+		screen.setSecondLinePrefix(func() []token.Token {
+			return renderContext.getSecondLinePrefix(prompt)
+		})
+	}
 
 	//    写入分词后的用户输入
 	screen.WriteTokens(renderContext.code.GetTokens(), true)
@@ -58,15 +76,46 @@ func (r *Renderer) getNewScreen(renderContext *RenderContext) *Screen {
 
 	screen.setSecondLinePrefix(nil)
 
+	//    写入右侧提示符，确定输入后不再显示
+	if rightPrompt, ok := prompt.(RightPrompt); ok && !accepted {
+		screen.writeRightPrompt(rightPrompt.GetRightPrompt())
+	}
+
 	//    写入补全菜单
 	if renderContext.completeState != nil {
-		newCompletionMenu(screen, renderContext.completeState, 7).write()
+		newCompletionMenu(screen, renderContext.completeState, renderContext.completionDisplay).write()
+	}
+
+	//    写入是否列出全部补全的询问
+	if renderContext.completionQuery > 0 {
+		screen.writeTokensBelow(getCompletionQueryTokens(renderContext.completionQuery))
+	}
+
+	//    写入输入检查错误
+	if renderContext.validationError != nil {
+		screen.writeTokensBelow([]token.Token{token.NewToken(token.ValidationError, renderContext.validationError.Message)})
+	}
+
+	//    写入组合键前缀提示
+	if renderContext.pendingKeys != "" {
+		screen.writeTokensBelow([]token.Token{token.NewToken(token.PendingKeys, renderContext.pendingKeys)})
+	}
+
+	//    写入底部工具栏，确定输入后不再显示
+	if r.toolbar != nil && !accepted {
+		screen.writeToolbar(r.toolbar())
 	}
 
 	return screen
 }
 
 func (r *Renderer) renderToStr(renderContext *RenderContext, abort bool, accept bool) string {
+	return r.renderScreenToStr(renderContext, accept || abort, accept || abort)
+}
+
+// renderScreenToStr accepted 表示用户已经确定（或者放弃）输入，
+// freeze 表示画完后另起一行，画出的内容保留在终端上，之后的渲染不会覆盖
+func (r *Renderer) renderScreenToStr(renderContext *RenderContext, accepted bool, freeze bool) string {
 	var buf bytes.Buffer
 
 	//    移动光标到输入的左上方
@@ -86,20 +135,32 @@ func (r *Renderer) renderToStr(renderContext *RenderContext, abort bool, accept
 	buf.WriteString(terminalcode.EraseDown)
 
 	//    写入屏幕输出
-	screen := r.getNewScreen(renderContext)
-	if !(accept || abort) {
+	screen := r.getNewScreen(renderContext, accepted)
+	if !accepted {
 		//    高亮对应区域
 		for _, sec := range renderContext.highlights {
 			start := screen.getCoordinateByLocation(sec.start)
 			end := screen.getCoordinateByLocation(sec.end)
 			screen.ReverseStyle(start, end)
 		}
+		//    高亮增量搜索匹配的文本
+		for _, sec := range renderContext.searchMatches {
+			start := screen.getCoordinateByLocation(sec.start)
+			end := screen.getCoordinateByLocation(sec.end)
+			screen.ApplyStyle(start, end, r.schema.StyleForToken(token.IncrementalSearchMatch))
+		}
+		//    高亮选中的文本
+		for _, sec := range renderContext.selections {
+			start := screen.getCoordinateByLocation(sec.start)
+			end := screen.getCoordinateByLocation(sec.end)
+			screen.ApplySelectionStyle(start, end)
+		}
 	}
 	o, lastCoordinate := screen.Output(offsetY)
 	buf.WriteString(o)
 
 	//    用户输入完毕或者放弃输入或者退出，另起一行
-	if accept || abort {
+	if freeze {
 		r.cursorCoordinate = Coordinate{0, 0}
 		buf.WriteString(terminalcode.CRLF)
 	} else {
@@ -130,15 +191,16 @@ func (r *Renderer) render(renderContext *RenderContext, abort bool, accept bool)
 	r.flush()
 }
 
-// renderCompletions 将补全选项一行行打印出来
-func (r *Renderer) renderCompletions(completions []*Completion) {
-	r.write(terminalcode.CRLF)
+// renderCompletions 保留当前的输入，在下方按列打印补全，之后的渲染会另起一行画出输入
+func (r *Renderer) renderCompletions(renderContext *RenderContext, completions []*Completion) {
+	//    保留完整的提示符（包括右侧提示符和工具栏），输入还没有确定
+	r.write(r.renderScreenToStr(renderContext, false, true))
 	items := make([]string, len(completions))
 	for i, completion := range completions {
 		items[i] = completion.Display
 	}
 
-	for _, line := range r.inColumns(items, 0) {
+	for _, line := range inColumns(items, r.getSize().width, 0) {
 		r.write(line)
 		r.write(terminalcode.CRLF)
 	}
@@ -147,20 +209,19 @@ func (r *Renderer) renderCompletions(completions []*Completion) {
 	r.cursorCoordinate = Coordinate{0, 0}
 }
 
-// inColumns 将词语按行自适应排列， marginLeft 左边空格数量
-func (r *Renderer) inColumns(items []string, marginLeft int) []string {
-	// 计算最宽的选项
-	maxWidth := 0
+// inColumns 将词语按行自适应排列， width 是终端宽度， marginLeft 左边空格数量
+func inColumns(items []string, width int, marginLeft int) []string {
+	// 计算最宽的选项，需要一个空格作为分割
+	maxWidth := 1
 	for _, item := range items {
-		w := runewidth.StringWidth(item)
+		w := runewidth.StringWidth(item) + 1
 		if w > maxWidth {
-			// 需要一个空格作为分割
-			maxWidth = w + 1
+			maxWidth = w
 		}
 	}
 
 	// 每行打印几个单词
-	termWidth := r.getSize().width - marginLeft
+	termWidth := width - marginLeft
 	wordsPerLine := termWidth / maxWidth
 	if wordsPerLine == 0 {
 		wordsPerLine = 1
@@ -177,12 +238,13 @@ func (r *Renderer) inColumns(items []string, marginLeft int) []string {
 		if (i+1)%wordsPerLine == 0 {
 			lines = append(lines, buf.String())
 			buf.Reset()
+			buf.WriteString(margin)
 		} else {
 			// 加上单词之间的空格
 			buf.WriteString(repeatByte(' ', maxWidth-runewidth.StringWidth(item)))
 		}
 	}
-	if buf.Len() > 0 {
+	if len(items)%wordsPerLine != 0 {
 		lines = append(lines, buf.String())
 	}
 	return lines
@@ -54,6 +54,17 @@ var defaultSchema = map[token.TokenType]*terminalcolor.ColorStyle{
 	token.CompletionMenuMeta:              terminalcolor.NewColorStyleHex("#cccccc", "#888888"),
 	token.CompletionMenuProgressBar:       terminalcolor.NewColorStyleHex("", "#aaaaaa"),
 	token.CompletionMenuProgressButton:    terminalcolor.NewColorStyleHex("", "#000000"),
+	token.CompletionMenuLoading:           terminalcolor.NewColorStyleHex("#ffffbb", "#888888"),
+	token.CompletionMenuMatch:             terminalcolor.NewColorStyleHex("#ffff00", "#888888"),
+	token.CompletionMenuMatchCurrent:      terminalcolor.NewColorStyleHex("#0000ff", "#dddddd"),
 
 	token.Selection: selectionStyleDefault,
+
+	token.IncrementalSearchMatch: terminalcolor.NewColorStyleHex("#000000", "#ffff88"),
+
+	token.PendingKeys: terminalcolor.NewFgColorStyleHex("#888888"),
+
+	token.Toolbar: terminalcolor.NewColorStyleHex("#ffffff", "#444444"),
+
+	token.ValidationError: terminalcolor.NewColorStyleHex("#ffffff", "#aa0000"),
 }
@@ -144,6 +144,36 @@ func (s *Screen) ReverseStyle(start Coordinate, end Coordinate) {
 	}
 }
 
+// ApplyStyle 将 [start, end) 区域的字符样式设置为 style
+func (s *Screen) ApplyStyle(start Coordinate, end Coordinate, style *terminalcolor.ColorStyle) {
+	current := start
+	for end.gt(&current) {
+		ch := s.getAtPos(current.X, current.Y)
+		if ch != nil {
+			ch.style = style
+		}
+		current.addX(1)
+		if current.X >= s.size.width {
+			current = Coordinate{0, current.Y + 1}
+		}
+	}
+}
+
+// ApplySelectionStyle 将 [start, end) 区域中输入的字符样式设置为选中样式，提示符等其他字符保持不变
+func (s *Screen) ApplySelectionStyle(start Coordinate, end Coordinate) {
+	current := start
+	for end.gt(&current) {
+		ch := s.getAtPos(current.X, current.Y)
+		if _, isInput := s.locationMap[current]; ch != nil && isInput {
+			ch.style = s.schema.StyleForSelection(ch.style)
+		}
+		current.addX(1)
+		if current.X >= s.size.width {
+			current = Coordinate{0, current.Y + 1}
+		}
+	}
+}
+
 func (s *Screen) Width() int {
 	return s.size.width
 }
@@ -227,6 +257,76 @@ func (s *Screen) WriteTokensAtPos(x int, y int, tokens []token.Token) {
 	}
 }
 
+// writeTokensBelow 在当前所有内容的下一行写入 token 数组
+func (s *Screen) writeTokensBelow(tokens []token.Token) {
+	x, y := 0, s.lastCoordinate.Y+1
+	for _, t := range tokens {
+		if t.TypeIs(token.EOF) {
+			break
+		}
+		style := s.schema.StyleForToken(t.Type)
+		for _, r := range t.Literal {
+			char := newChar(r, style)
+			s.writeAtPos(x, y, char)
+			x += char.width()
+		}
+	}
+}
+
+// writeRightPrompt 在输入第一行的右侧写入提示符，跟输入重叠时不写入
+func (s *Screen) writeRightPrompt(tokens []token.Token) {
+	width := 0
+	for _, t := range tokens {
+		if t.TypeIs(token.EOF) {
+			break
+		}
+		width += runewidth.StringWidth(t.Literal)
+	}
+	if width == 0 {
+		return
+	}
+	//    跟 zsh 一样右边空出一列，避免写到最后一列时终端自动换行
+	x := s.size.width - width - 1
+	y := s.getCoordinateByLocation(Location{0, 0}).Y
+	//    跟输入之间至少留一个空格
+	end := 0
+	for cx, char := range s.buffer[y] {
+		end = maxInt(end, cx+char.width())
+	}
+	if x <= end {
+		return
+	}
+	for _, t := range tokens {
+		if t.TypeIs(token.EOF) {
+			break
+		}
+		style := s.schema.StyleForToken(t.Type)
+		for _, r := range t.Literal {
+			char := newChar(r, style)
+			s.writeAtPos(x, y, char)
+			x += char.width()
+		}
+	}
+}
+
+// writeToolbar 在最下方另起一行写入工具栏，工具栏的背景铺满整行
+func (s *Screen) writeToolbar(tokens []token.Token) {
+	if len(tokens) == 0 {
+		return
+	}
+	y := s.lastCoordinate.Y + 1
+	s.writeTokensBelow(tokens)
+	end := 0
+	for x, char := range s.buffer[y] {
+		end = maxInt(end, x+char.width())
+	}
+	//    同样空出最后一列，避免终端自动换行
+	style := s.schema.StyleForToken(token.Toolbar)
+	for x := end; x < s.size.width-1; x++ {
+		s.writeAtPos(x, y, newChar(' ', style))
+	}
+}
+
 // WriteTokens 写入 Token 数组， saveInputPos: 是否保存输入位置
 // 对于用户输入的内容才会保存输入位置，以便确定光标的位置，想补全列表就不属于输入
 func (s *Screen) WriteTokens(tokens []token.Token, saveInputPos bool) {
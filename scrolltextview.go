@@ -2,8 +2,6 @@ package startprompt
 
 import (
 	"strings"
-
-	"golang.design/x/clipboard"
 )
 
 type xChar struct {
@@ -29,10 +27,12 @@ type sScrollTextView struct {
 	offsetLimitY int
 	//    选中区域
 	selection area
+	//    鼠标选中的文本会复制到剪贴板
+	clipboard Clipboard
 }
 
-func newScrollTextView() *sScrollTextView {
-	return &sScrollTextView{data: [][]xChar{nil}}
+func newScrollTextView(clipboard Clipboard) *sScrollTextView {
+	return &sScrollTextView{data: [][]xChar{nil}, clipboard: clipboard}
 }
 
 // growTo 增加数据长度， y 是从 0 开始的索引
@@ -308,11 +308,13 @@ func (st *sScrollTextView) update() {
 		return
 	}
 
-	//   将选中文本复制到系统剪贴板
+	//   将选中文本复制到剪贴板
 	//   文本发生变化时复制一次
 	text := st.getSelectionText()
 	if text != st.selectionText {
-		clipboard.Write(clipboard.FmtText, []byte(text))
+		if err := st.clipboard.Write(text); err != nil {
+			DebugLog("clipboard write error: %v", err)
+		}
 		st.selectionText = text
 	}
 
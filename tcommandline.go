@@ -1,6 +1,7 @@
 package startprompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime/debug"
@@ -8,7 +9,6 @@ import (
 	"time"
 
 	"github.com/gdamore/tcell/v2"
-	"golang.design/x/clipboard"
 	"golang.org/x/term"
 )
 
@@ -23,15 +23,19 @@ type outputStruct struct {
 }
 
 var defaultTCommandLineOption = &CommandLineOption{
-	Schema:        defaultSchema,
-	Handler:       newTBaseEventHandler(),
-	History:       NewMemHistory(),
-	CodeFactory:   newBaseCode,
-	PromptFactory: newBasePrompt,
-	OnAbort:       AbortActionRetry,
-	OnExit:        AbortActionReturnError,
-	AutoIndent:    false,
-	EnableDebug:   false,
+	Schema:               defaultSchema,
+	Handler:              newTBaseEventHandler(),
+	History:              NewMemHistory(),
+	CodeFactory:          newBaseCode,
+	PromptFactory:        newBasePrompt,
+	ChordTimeout:         defaultChordTimeout,
+	CompletionDisplay:    CompletionDisplayColumn,
+	CompletionMenuHeight: defaultCompletionMenuHeight,
+	CompletionQueryItems: defaultCompletionQueryItems,
+	OnAbort:              AbortActionRetry,
+	OnExit:               AbortActionReturnError,
+	AutoIndent:           false,
+	EnableDebug:          false,
 }
 
 type TCommandLine struct {
@@ -43,6 +47,9 @@ type TCommandLine struct {
 	mousePrimaryPressed bool
 	lastPrimaryEvent    *tcell.EventMouse
 	lastDblclickEvent   *tcell.EventMouse
+	//    是否正在接收粘贴的文本，以及已经接收的文本
+	pasting     bool
+	pasteBuffer []rune
 	//    点击间隔，用来判断鼠标双击、三击等
 	clickInterval time.Duration
 	//    配置选项
@@ -68,6 +75,14 @@ type TCommandLine struct {
 	exitFlag   bool
 	abortFlag  bool
 	acceptFlag bool
+	//    多次输入共用 kill ring
+	killRing *cKillRing
+	//    按键分发，处理组合键
+	keyDispatcher cKeyDispatcher
+	//    其他协程对当前输入的修改
+	invokeQueue *cInvokeQueue
+	//    ReadInputContext 的 ctx 取消时，传递 ctx.Err() 给 runLoop
+	cancelChannel chan error
 	//    wg 用来等待协程结束
 	wg sync.WaitGroup
 }
@@ -81,17 +96,21 @@ func NewTCommandLine(option *CommandLineOption) (*TCommandLine, error) {
 		return nil, fmt.Errorf("not in a terminal")
 	}
 
-	//     Init returns an error if the package is not ready for use.
-	err := clipboard.Init()
-	if err != nil {
-		return nil, err
-	}
-
 	//    update option default
 	actualOption := defaultTCommandLineOption.copy()
 	if option != nil {
 		actualOption.update(option)
 	}
+	if actualOption.Clipboard == nil {
+		//    没有图形界面（比如通过 SSH 连接）时系统剪贴板不可用，改用内存剪贴板
+		//    不默认使用 OSC 52 ，因为 tcell 接管了终端，绕过 tcell 直接写 os.Stdout 可能打乱屏幕
+		if systemClipboard, err := NewSystemClipboard(); err == nil {
+			actualOption.Clipboard = systemClipboard
+		} else {
+			DebugLog("system clipboard unavailable: %v", err)
+			actualOption.Clipboard = NewMemClipboard()
+		}
+	}
 
 	//     Initialize screen
 	s, err := tcell.NewScreen()
@@ -115,11 +134,14 @@ func NewTCommandLine(option *CommandLineOption) (*TCommandLine, error) {
 		inputChannel:  make(chan *inputStruct),
 		redrawChannel: make(chan struct{}, 16),
 		closeChannel:  make(chan struct{}),
+		cancelChannel: make(chan error, 1),
 		outputChannel: make(chan outputStruct, 16),
 		tEventChannel: make(chan tcell.Event, 1024),
 		tQuitChannel:  make(chan struct{}),
 
-		renderer: newTRenderer(s, actualOption.Schema, actualOption.PromptFactory),
+		renderer:    newTRenderer(s, actualOption.Schema, actualOption.PromptFactory, actualOption.Toolbar, actualOption.Clipboard),
+		killRing:    newKillRing(),
+		invokeQueue: newInvokeQueue(),
 	}
 	c.setup()
 	DebugLog("start tcommandline")
@@ -150,6 +172,7 @@ func (tc *TCommandLine) reset() {
 	tc.exitFlag = false
 	tc.abortFlag = false
 	tc.acceptFlag = false
+	tc.keyDispatcher.reset()
 }
 
 // Close 关闭命令行，恢复终端到原先的模式
@@ -159,7 +182,6 @@ func (tc *TCommandLine) Close() {
 	tc.tscreen.DisableMouse()
 	tc.running = false
 	close(tc.closeChannel)
-	close(tc.redrawChannel)
 	close(tc.outputChannel)
 	tc.wg.Wait()
 	//    继续读取事件
@@ -177,12 +199,30 @@ func (tc *TCommandLine) Close() {
 }
 
 // RequestRedraw 请求重绘（ goroutine 安全）
+//
+//	不会阻塞，已经有重绘请求没有处理时，本次请求会合并到之前的请求中
 func (tc *TCommandLine) RequestRedraw() {
-	if tc.isReadingInput {
-		tc.redrawChannel <- struct{}{}
+	select {
+	case tc.redrawChannel <- struct{}{}:
+	default:
 	}
 }
 
+// Invoke 在事件循环中修改当前输入，修改后重绘（ goroutine 安全）
+//
+//	没有在读取输入时，修改会在下次 ReadInput 开始时执行，可以用来预先填充输入
+func (tc *TCommandLine) Invoke(fn func(line *Line)) {
+	tc.invokeQueue.push(fn)
+	tc.RequestRedraw()
+}
+
+// SetText 替换当前输入的文本并设置光标位置（ goroutine 安全），见 Invoke
+func (tc *TCommandLine) SetText(text string, cursor int) {
+	tc.Invoke(func(line *Line) {
+		line.SetText(text, cursor)
+	})
+}
+
 // RunInExecutor 运行后台任务
 func (tc *TCommandLine) RunInExecutor(callback func()) {
 	go callback()
@@ -190,14 +230,34 @@ func (tc *TCommandLine) RunInExecutor(callback func()) {
 
 // ReadInput 读取当前输入
 func (tc *TCommandLine) ReadInput() (string, error) {
+	return tc.ReadInputContext(context.Background())
+}
+
+// ReadInputContext 读取当前输入， ctx 取消（或者超时）时返回已经输入的文本和 ctx.Err()
+func (tc *TCommandLine) ReadInputContext(ctx context.Context) (string, error) {
 	if tc.isReadingInput {
 		return "", fmt.Errorf("already reading input")
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	tc.isReadingInput = true
 	DebugLog("reading input")
 
 	tc.outputChannel <- outputStruct{"", true}
-	in := <-tc.inputChannel
+	var in *inputStruct
+	select {
+	case in = <-tc.inputChannel:
+	case <-ctx.Done():
+		//    runLoop 可能同时返回了用户输入，所以这里还是要等待 inputChannel
+		tc.cancelChannel <- ctx.Err()
+		in = <-tc.inputChannel
+		//    丢弃没有被处理的取消，以免影响下次输入
+		select {
+		case <-tc.cancelChannel:
+		default:
+		}
+	}
 
 	tc.isReadingInput = false
 	DebugLog("return input: <%s>, err: %v", in.text, in.err)
@@ -246,17 +306,23 @@ func (tc *TCommandLine) runLoop() {
 		tc.option.History,
 		tc.option.AutoIndent,
 	)
+	line.killRing = tc.killRing
+	line.clipboard = tc.option.Clipboard
+	line.completionDisplay = newCompletionDisplayOption(tc.option)
+	line.invoke = tc.Invoke
 	tc.line = line
 
-	renderer.render(line.GetRenderContext(), false, false)
-
 	resetFunc := func() {
 		line.reset()
+		initHandlerLine(tc.option.Handler, line)
 		renderer.reset()
 		tc.reset()
 	}
 
 	resetFunc()
+	//    执行读取输入前添加的修改
+	tc.runInvokes()
+	renderer.render(line.GetRenderContext(), false, false)
 
 	for {
 		if len(tc.tEventKeyChannel) == 0 {
@@ -264,6 +330,19 @@ func (tc *TCommandLine) runLoop() {
 			case <-tc.closeChannel:
 				DebugLog("close")
 				return
+			case err := <-tc.cancelChannel:
+				//    跟放弃输入一样另起一行，返回已经输入的文本
+				DebugLog("cancel input: %v", err)
+				//    正在进行的异步补全也一起取消，不会在读取结束后继续运行
+				line.cancelAsyncComplete()
+				renderer.render(line.GetRenderContext(), true, false)
+				tc.sendInput(line.text(), err)
+				return
+			case <-tc.keyDispatcher.timeoutChannel(tc.option.ChordTimeout):
+				//    组合键等待后续按键超时
+				if !tc.keyDispatcher.timeout(tc.option.KeyBindings, tc.option.Handler, tc.option.ChordTimeout) {
+					continue
+				}
 			case <-tc.redrawChannel:
 				DebugLog("redraw")
 				//    将缓冲的信息都读取出来，以免循环中不断触发
@@ -271,9 +350,12 @@ func (tc *TCommandLine) runLoop() {
 				for i := 0; i < loop; i++ {
 					<-tc.redrawChannel
 				}
-				//    渲染用户输入
-				renderer.render(line.GetRenderContext(), false, false)
-				continue
+				//    其他协程通过 Invoke 修改了输入，需要处理修改的结果（比如确定输入）
+				if !tc.runInvokes() {
+					//    渲染用户输入
+					renderer.render(line.GetRenderContext(), false, false)
+					continue
+				}
 			case ev := <-tc.tEventChannel:
 				eventEmited := tc.emitEvent(ev)
 				//    非阻塞的读取后续事件，优化粘贴大量文本的情况，快速处理，减少多次 render 导致的停顿感
@@ -348,6 +430,11 @@ func (tc *TCommandLine) runLoop() {
 			break
 		}
 
+		//    在输入下方列出补全（见 CompletionDisplayReadline ）
+		if completions := line.takeCompletionListing(); completions != nil {
+			renderer.renderCompletions(line.GetRenderContext(), completions)
+		}
+
 		renderer.update()
 		//    画出用户输入
 		renderer.render(line.GetRenderContext(), false, false)
@@ -411,14 +498,50 @@ func (tc *TCommandLine) emitEvent(tevent tcell.Event) bool {
 	switch ev := tevent.(type) {
 	case *tcell.EventResize:
 		tc.renderer.Resize()
+	case *tcell.EventPaste:
+		if ev.Start() {
+			tc.pasting = true
+			tc.pasteBuffer = nil
+		} else if tc.pasting {
+			tc.pasting = false
+			event = NewEventKey(EventTypePaste, tc.pasteBuffer, nil, tc)
+			tc.pasteBuffer = nil
+		}
 	case *tcell.EventKey:
+		//    粘贴的文本原样保存，粘贴结束时作为一个粘贴事件处理
+		if tc.pasting {
+			switch ev.Key() {
+			case tcell.KeyRune:
+				tc.pasteBuffer = append(tc.pasteBuffer, ev.Rune())
+			case tcell.KeyEnter, tcell.KeyLF:
+				tc.pasteBuffer = append(tc.pasteBuffer, '\n')
+			case tcell.KeyTab:
+				tc.pasteBuffer = append(tc.pasteBuffer, '\t')
+			}
+			return false
+		}
 		eventType, found := tkeyMapping[ev.Key()]
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			//    跟 InputStream 一样，按住 Alt 时映射成 Meta 事件
+			switch ev.Key() {
+			case tcell.KeyRune:
+				if metaEventType, ok := tmetaKeyMapping[ev.Rune()]; ok {
+					eventType = metaEventType
+				}
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				eventType, found = EventTypeMetaBackspace, true
+			}
+		}
 		if found {
 			var data []rune
 			if ev.Key() == tcell.KeyRune {
 				data = []rune{ev.Rune()}
 			}
-			event = NewEventKey(eventType, data, nil, tc)
+			ek := NewEventKey(eventType, data, nil, tc)
+			if isModifiableEvent(eventType) {
+				ek.modifiers = tkeyModifiers(ev.Modifiers())
+			}
+			event = ek
 		} else {
 			DebugLog("unsupported tcell.EventKey: %+v", ev)
 		}
@@ -459,7 +582,7 @@ func (tc *TCommandLine) emitEvent(tevent tcell.Event) bool {
 		return false
 	}
 	DebugLog("emit event=%s", event.Type())
-	tc.option.Handler.Handle(event)
+	tc.keyDispatcher.dispatch(tc.option.KeyBindings, tc.option.Handler, event)
 	return true
 }
 
@@ -553,6 +676,18 @@ func (tc *TCommandLine) SetOnExit(action AbortAction) {
 	tc.option.OnExit = action
 }
 
+// runInvokes 执行其他协程对当前输入的修改，返回 false 表示没有修改
+func (tc *TCommandLine) runInvokes() bool {
+	if !tc.invokeQueue.run(tc.line) {
+		return false
+	}
+	//    跟按键事件一样，修改中确定了输入时设置完成标志
+	if tc.line.IsAccept() {
+		tc.SetAccept()
+	}
+	return true
+}
+
 func (tc *TCommandLine) SetExit() {
 	tc.exitFlag = true
 }
@@ -35,6 +35,13 @@ const (
 	EnableX10Mouse  = "\x1b[?9h"
 	DisableX10Mouse = "\x1b[?9l"
 
+	// EnableBracketedPaste 开启 bracketed paste ，粘贴的文本前后会加上 BracketedPasteStart 和 BracketedPasteEnd
+	// ref: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h2-Bracketed-Paste-Mode
+	EnableBracketedPaste  = "\x1b[?2004h"
+	DisableBracketedPaste = "\x1b[?2004l"
+	BracketedPasteStart   = "\x1b[200~"
+	BracketedPasteEnd     = "\x1b[201~"
+
 	// RequestCursorPosition 请求光标位置 ref: https://vt100.net/docs/vt510-rm/CPR.html
 	RequestCursorPosition = "\x1b[6n"
 )
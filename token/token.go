@@ -116,6 +116,8 @@ const (
 
 	Prompt                 TokenType = "prompt"
 	PromptSecondLinePrefix TokenType = Prompt + ".secondlineprefix"
+	// PromptMode 提示符中的编辑模式标识
+	PromptMode TokenType = Prompt + ".mode"
 
 	CompletionMenu                  TokenType = "completionmenu"
 	CompletionMenuCompletion        TokenType = CompletionMenu + ".completion"
@@ -124,9 +126,28 @@ const (
 	CompletionMenuMetaCurrent       TokenType = CompletionMenuMeta + ".current"
 	CompletionMenuProgressButton    TokenType = CompletionMenu + ".progressbutton"
 	CompletionMenuProgressBar       TokenType = CompletionMenu + ".progressbar"
+	CompletionMenuLoading           TokenType = CompletionMenu + ".loading"
+	CompletionMenuMatch             TokenType = CompletionMenu + ".match"
+	CompletionMenuMatchCurrent      TokenType = CompletionMenuMatch + ".current"
 
 	Selection TokenType = "selection"
 
+	IncrementalSearch       TokenType = "incrementalsearch"
+	IncrementalSearchPrompt TokenType = IncrementalSearch + ".prompt"
+	IncrementalSearchMatch  TokenType = IncrementalSearch + ".match"
+
+	// PendingKeys 组合键已经按下的前缀提示
+	PendingKeys TokenType = "pendingkeys"
+
+	// CompletionQuery 询问是否列出全部补全，比如 "Display all 300 possibilities? (y/n)"
+	CompletionQuery TokenType = "completionquery"
+
+	// Toolbar 底部工具栏
+	Toolbar TokenType = "toolbar"
+
+	// ValidationError 输入检查的错误信息
+	ValidationError TokenType = "validationerror"
+
 	EOF TokenType = "EOF"
 )
 
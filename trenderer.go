@@ -1,6 +1,8 @@
 package startprompt
 
 import (
+	"strings"
+
 	"github.com/gdamore/tcell/v2"
 
 	"github.com/yetsing/startprompt/terminalcolor"
@@ -23,6 +25,8 @@ type TRenderer struct {
 
 	schema        Schema
 	promptFactory PromptFactory
+	//    返回底部工具栏的内容
+	toolbar func() []token.Token
 
 	//    xy 坐标到输入行列的映射
 	inputLocationMap map[Coordinate]Location
@@ -35,10 +39,17 @@ type TRenderer struct {
 	triggerEventMouse bool
 }
 
-func newTRenderer(tscreen tcell.Screen, schema Schema, promptFactory PromptFactory) *TRenderer {
+func newTRenderer(
+	tscreen tcell.Screen,
+	schema Schema,
+	promptFactory PromptFactory,
+	toolbar func() []token.Token,
+	clipboard Clipboard,
+) *TRenderer {
 	return &TRenderer{
+		toolbar:        toolbar,
 		tscreen:        tscreen,
-		scrollTextView: newScrollTextView(),
+		scrollTextView: newScrollTextView(clipboard),
 		schema:         schema,
 		promptFactory:  promptFactory,
 	}
@@ -52,17 +63,23 @@ func (tr *TRenderer) getSize() _Size {
 	}
 }
 
-func (tr *TRenderer) getNewScreen(renderContext *RenderContext) *Screen {
+// getNewScreen accepted 表示用户已经确定（或者放弃）输入
+func (tr *TRenderer) getNewScreen(renderContext *RenderContext, accepted bool) *Screen {
 	screen := NewScreen(tr.schema, tr.getSize())
 
 	//    写入提示符
 	prompt := tr.promptFactory(renderContext.code)
-	prompts := prompt.GetPrompt()
-	screen.WriteTokens(prompts, false)
-	//    设置后续行前缀函数
-	screen.setSecondLinePrefix(func() []token.Token {
-		return prompt.GetSecondLinePrefix()
-	})
+	if transientPrompt, ok := prompt.(TransientPrompt); ok && accepted {
+		//    确定输入后改用简短的提示符，后续行不加前缀
+		screen.WriteTokens(transientPrompt.GetTransientPrompt(), false)
+	} else {
+		prompts := renderContext.getPrompt(prompt)
+		screen.WriteTokens(prompts, false)
+		//    设置后续行前缀函数
+		screen.setSecondLinePrefix(func() []token.Token {
+			return renderContext.getSecondLinePrefix(prompt)
+		})
+	}
 
 	//    写入分词后的用户输入
 	screen.WriteTokens(renderContext.code.GetTokens(), true)
@@ -70,10 +87,15 @@ func (tr *TRenderer) getNewScreen(renderContext *RenderContext) *Screen {
 
 	screen.setSecondLinePrefix(nil)
 
+	//    写入右侧提示符，确定输入后不再显示
+	if rightPrompt, ok := prompt.(RightPrompt); ok && !accepted {
+		screen.writeRightPrompt(rightPrompt.GetRightPrompt())
+	}
+
 	//    写入补全菜单
 	tr.completionMenuInfo = nil
 	if renderContext.completeState != nil {
-		menu := newCompletionMenu(screen, renderContext.completeState, 7)
+		menu := newCompletionMenu(screen, renderContext.completeState, renderContext.completionDisplay)
 		menu.write()
 		tr.completionMenuInfo = menu.getInfo()
 		//    转换补全的坐标为窗口坐标
@@ -82,6 +104,26 @@ func (tr *TRenderer) getNewScreen(renderContext *RenderContext) *Screen {
 		tr.completionMenuInfo.area.end.addY(inputStartCoordinate.Y)
 	}
 
+	//    写入是否列出全部补全的询问
+	if renderContext.completionQuery > 0 {
+		screen.writeTokensBelow(getCompletionQueryTokens(renderContext.completionQuery))
+	}
+
+	//    写入输入检查错误
+	if renderContext.validationError != nil {
+		screen.writeTokensBelow([]token.Token{token.NewToken(token.ValidationError, renderContext.validationError.Message)})
+	}
+
+	//    写入组合键前缀提示
+	if renderContext.pendingKeys != "" {
+		screen.writeTokensBelow([]token.Token{token.NewToken(token.PendingKeys, renderContext.pendingKeys)})
+	}
+
+	//    写入底部工具栏，确定输入后不再显示
+	if tr.toolbar != nil && !accepted {
+		screen.writeToolbar(tr.toolbar())
+	}
+
 	return screen
 }
 
@@ -100,15 +142,33 @@ func (tr *TRenderer) updateWithScreen(screen *Screen) {
 }
 
 func (tr *TRenderer) render(renderContext *RenderContext, abort bool, accept bool) {
+	tr.renderScreen(renderContext, accept || abort, accept || abort)
+}
+
+// renderScreen accepted 表示用户已经确定（或者放弃）输入，
+// freeze 表示画完后另起一行，画出的内容保留在终端上，之后的渲染不会覆盖
+func (tr *TRenderer) renderScreen(renderContext *RenderContext, accepted bool, freeze bool) {
 	//    写入屏幕输出
-	screen := tr.getNewScreen(renderContext)
-	if !(accept || abort) {
+	screen := tr.getNewScreen(renderContext, accepted)
+	if !accepted {
 		//    高亮对应区域
 		for _, sec := range renderContext.highlights {
 			start := screen.getCoordinateByLocation(sec.start)
 			end := screen.getCoordinateByLocation(sec.end)
 			screen.ReverseStyle(start, end)
 		}
+		//    高亮增量搜索匹配的文本
+		for _, sec := range renderContext.searchMatches {
+			start := screen.getCoordinateByLocation(sec.start)
+			end := screen.getCoordinateByLocation(sec.end)
+			screen.ApplyStyle(start, end, tr.schema.StyleForToken(token.IncrementalSearchMatch))
+		}
+		//    高亮选中的文本
+		for _, sec := range renderContext.selections {
+			start := screen.getCoordinateByLocation(sec.start)
+			end := screen.getCoordinateByLocation(sec.end)
+			screen.ApplySelectionStyle(start, end)
+		}
 	}
 	tr.updateWithScreen(screen)
 
@@ -117,7 +177,7 @@ func (tr *TRenderer) render(renderContext *RenderContext, abort bool, accept boo
 	}
 
 	//    用户输入完毕或者放弃输入或者退出，另起一行
-	if accept || abort {
+	if freeze {
 		tr.scrollTextView.acceptInput()
 		tr.cursorRelativeCoordinate = Coordinate{}
 	} else {
@@ -143,6 +203,18 @@ func (tr *TRenderer) renderOutput(output string) {
 	tr.Show()
 }
 
+// renderCompletions 保留当前的输入，在下方按列打印补全，之后的渲染会另起一行画出输入
+func (tr *TRenderer) renderCompletions(renderContext *RenderContext, completions []*Completion) {
+	//    保留完整的提示符（包括右侧提示符和工具栏），输入还没有确定
+	tr.renderScreen(renderContext, false, true)
+	items := make([]string, len(completions))
+	for i, completion := range completions {
+		items[i] = completion.Display
+	}
+	lines := inColumns(items, tr.getSize().width, 0)
+	tr.renderOutput(strings.Join(lines, "\n") + "\n")
+}
+
 func (tr *TRenderer) update() {
 	tr.scrollTextView.update()
 }
@@ -38,6 +38,29 @@ func getSize(fd int) (int, int) {
 	return width, height
 }
 
+// terminalFd 返回 v 对应的终端文件描述符，不是终端返回 -1
+func terminalFd(v any) int {
+	if f, ok := v.(interface{ Fd() uintptr }); ok {
+		fd := int(f.Fd())
+		if term.IsTerminal(fd) {
+			return fd
+		}
+	}
+	return -1
+}
+
+// newTerminalSizeFunc 返回获取终端窗口大小的函数，依次尝试 fds 中的终端，都不是终端时返回 80x24
+func newTerminalSizeFunc(fds ...int) func() (int, int) {
+	return func() (int, int) {
+		for _, fd := range fds {
+			if fd != -1 {
+				return getSize(fd)
+			}
+		}
+		return 80, 24
+	}
+}
+
 func repeatByte(c byte, count int) string {
 	var b bytes.Buffer
 	for i := 0; i < count; i++ {
@@ -86,6 +109,38 @@ func findRunes(runes []rune, r rune) int {
 	return -1
 }
 
+// searchRunes 在 runes 中查找 sub ，返回匹配位置，找不到返回 -1
+// backward 为 true 时返回不大于 from 的最后一个匹配，否则返回不小于 from 的第一个匹配
+func searchRunes(runes []rune, sub []rune, from int, backward bool) int {
+	last := len(runes) - len(sub)
+	if backward {
+		for i := minInt(from, last); i >= 0; i-- {
+			if equalRunes(runes[i:i+len(sub)], sub) {
+				return i
+			}
+		}
+	} else {
+		for i := maxInt(from, 0); i <= last; i++ {
+			if equalRunes(runes[i:i+len(sub)], sub) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func equalRunes(a []rune, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func concatRunes(a ...[]rune) []rune {
 	resultLength := 0
 	for _, runes := range a {
@@ -156,3 +211,13 @@ func IsSpace(s string) bool {
 	}
 	return true
 }
+
+// commonPrefix 返回两个字符串共同的前缀（按字符比较）
+func commonPrefix(a string, b string) string {
+	ar, br := []rune(a), []rune(b)
+	n := 0
+	for n < len(ar) && n < len(br) && ar[n] == br[n] {
+		n++
+	}
+	return string(ar[:n])
+}
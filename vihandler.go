@@ -0,0 +1,626 @@
+package startprompt
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yetsing/startprompt/enums/linemode"
+)
+
+/*
+vi 编辑模式的事件处理器，支持 normal insert visual 三种模式
+insert 模式下的按键跟默认的处理器一样； normal 和 visual 模式下输入的字符会作为 vi 命令处理
+*/
+
+// _ViMotion 移动命令，根据 document 返回移动后的光标位置，返回 -1 表示移动失败（比如 f 找不到字符）
+type _ViMotion func(document *Document) int
+
+// ViHandler vi 编辑模式的事件处理器， CommandLine 和 TCommandLine 都可以使用
+type ViHandler struct {
+	//    insert 模式以及 vi 没有处理的按键，交给默认的处理器
+	base  *BaseHandler
+	tbase *TBaseEventHandler
+
+	line *Line
+
+	//    下面几个是正在输入的命令，比如 "2d3w"
+	//    操作符前面的数字
+	count int
+	//    操作符后面的数字
+	operatorCount int
+	//    操作符 d c y ，没有时为 0
+	operator rune
+	//    等待输入字符的命令 f t F T r ，没有时为 0
+	pending rune
+
+	//    上一次 f t F T 的命令和字符，用于 ; 和 , 重复查找
+	lastFind     rune
+	lastFindChar rune
+	//    最近一次整行删除或者复制（比如 dd yy ）的文本，带上末尾的换行符，粘贴这个文本时按整行粘贴
+	//    因为带上了换行符，复制空行时也不是空字符串
+	linewiseText string
+	//    visual 模式开始的位置
+	visualStart int
+}
+
+// NewViHandler 新建 vi 编辑模式的事件处理器
+//
+//	c, err := startprompt.NewCommandLine(&startprompt.CommandLineOption{
+//		Handler: startprompt.NewViHandler(),
+//	})
+func NewViHandler() *ViHandler {
+	return &ViHandler{
+		base:  newBaseHandler(),
+		tbase: newTBaseEventHandler(),
+	}
+}
+
+// initLine 每次输入都从 insert 模式开始
+func (v *ViHandler) initLine(line *Line) {
+	v.line = line
+	v.resetCommand()
+	line.SetEditMode(linemode.ViInsert)
+}
+
+func (v *ViHandler) Handle(event Event) {
+	ek, ok := event.(*EventKey)
+	if !ok {
+		//    鼠标事件
+		v.fallback(event)
+		return
+	}
+	if ek.cli != nil {
+		v.line = ek.cli.GetLine()
+	} else {
+		v.line = ek.GetTCommandLine().GetLine()
+	}
+	line := v.line
+	if line.EditMode() == "" {
+		line.SetEditMode(linemode.ViInsert)
+	}
+	//    vi 中没有 Meta 组合键，快速按下 Esc 和字符被识别成的 Meta 组合键，拆开成两个按键处理
+	if keyType, keyData, ok := metaKeyOf(ek.Type()); ok {
+		v.Handle(NewEventKey(EventTypeEscape, []rune{'\x1b'}, ek.cli, ek.tcli))
+		v.Handle(NewEventKey(keyType, keyData, ek.cli, ek.tcli))
+		return
+	}
+
+	//    询问是否列出全部补全时，按键交给默认的处理器回答
+	if line.mode.Is(linemode.CompletionQuery) {
+		v.fallback(event)
+		return
+	}
+
+	if line.EditMode().Is(linemode.ViInsert) {
+		v.fallback(event)
+		if ek.Type() == EventTypeEscape {
+			v.toNormalMode()
+			//    跟 vi 一样，回到 normal 模式时光标左移一格
+			if line.Document().CurrentLineBeforeCursor() != "" {
+				line.CursorLeft()
+			}
+		}
+		return
+	}
+
+	switch ek.Type() {
+	case EventTypeInsertChar:
+		data := ek.GetData()
+		for i, r := range data {
+			v.feed(r)
+			v.adjustCursor()
+			//    命令切换到了 insert 模式，剩下的字符作为输入插入
+			if line.EditMode().Is(linemode.ViInsert) {
+				if i+1 < len(data) {
+					v.fallback(NewEventKey(EventTypeInsertChar, data[i+1:], ek.cli, ek.tcli))
+				}
+				return
+			}
+		}
+	case EventTypeEscape:
+		v.resetCommand()
+		if line.EditMode().Is(linemode.ViVisual) {
+			v.toNormalMode()
+		}
+	case EventTypeCtrlR:
+		v.resetCommand()
+		line.Redo()
+	default:
+		v.resetCommand()
+		v.fallback(event)
+	}
+	v.adjustCursor()
+}
+
+// fallback 交给默认的处理器
+func (v *ViHandler) fallback(event Event) {
+	var cli *CommandLine
+	switch ev := event.(type) {
+	case *EventKey:
+		cli = ev.cli
+	case *EventMouse:
+		cli = ev.cli
+	}
+	if cli != nil {
+		v.base.Handle(event)
+	} else {
+		v.tbase.Handle(event)
+	}
+}
+
+func (v *ViHandler) resetCommand() {
+	v.count = 0
+	v.operatorCount = 0
+	v.operator = 0
+	v.pending = 0
+}
+
+// takeCount 返回命令的重复次数，并清空输入的数字
+func (v *ViHandler) takeCount() int {
+	count := maxInt(v.count, 1) * maxInt(v.operatorCount, 1)
+	v.count = 0
+	v.operatorCount = 0
+	return count
+}
+
+func (v *ViHandler) toNormalMode() {
+	v.line.ToNormalMode()
+	v.line.selection = _LineArea{-1, -1}
+	v.line.SetEditMode(linemode.ViNormal)
+}
+
+func (v *ViHandler) toInsertMode() {
+	v.line.SetEditMode(linemode.ViInsert)
+}
+
+func (v *ViHandler) toVisualMode() {
+	v.visualStart = v.line.cursorPosition
+	v.line.SetEditMode(linemode.ViVisual)
+	v.updateSelection()
+}
+
+// updateSelection visual 模式下选中从开始位置到光标处（包括光标处）的文本
+func (v *ViHandler) updateSelection() {
+	start, end := v.visualStart, v.line.cursorPosition
+	if start > end {
+		start, end = end, start
+	}
+	v.line.selection = _LineArea{start, minInt(end+1, len(v.line.buffer))}
+}
+
+// adjustCursor normal 模式下光标不能停在行尾（除非是空行）
+func (v *ViHandler) adjustCursor() {
+	line := v.line
+	if line.EditMode().Is(linemode.ViInsert) {
+		return
+	}
+	document := line.Document()
+	if document.isCursorAtTheEndOfLine() && document.CurrentLineBeforeCursor() != "" {
+		line.CursorLeft()
+	}
+	if line.EditMode().Is(linemode.ViVisual) {
+		v.updateSelection()
+	}
+}
+
+// feed 处理 normal 和 visual 模式下输入的字符
+func (v *ViHandler) feed(r rune) {
+	line := v.line
+	if v.pending != 0 {
+		command := v.pending
+		v.pending = 0
+		if command == 'r' {
+			v.replaceCharacter(r)
+			return
+		}
+		v.lastFind, v.lastFindChar = command, r
+		v.motion(findCharMotion(command, r), command == 'f' || command == 't')
+		return
+	}
+
+	//    数字，注意 0 在没有输入数字时是移动到行首
+	counting := v.count > 0
+	if v.operator != 0 {
+		counting = v.operatorCount > 0
+	}
+	if unicode.IsDigit(r) && (r != '0' || counting) {
+		n := int(r - '0')
+		if v.operator == 0 {
+			v.count = v.count*10 + n
+		} else {
+			v.operatorCount = v.operatorCount*10 + n
+		}
+		return
+	}
+
+	//    新命令开始前保存，支持 undo
+	if v.operator == 0 {
+		line.SaveToUndoStack()
+	}
+
+	visual := line.EditMode().Is(linemode.ViVisual)
+	switch r {
+	case 'h':
+		v.motion(viMotionLeft, false)
+	case 'l', ' ':
+		v.motion(viMotionRight, false)
+	case 'w':
+		if v.operator == 'c' {
+			//    跟 vi 一样， cw 相当于 ce
+			v.motion(viMotionWordEnd, true)
+		} else {
+			v.motion(viMotionWordForward, false)
+		}
+	case 'b':
+		v.motion(viMotionWordBack, false)
+	case 'e':
+		v.motion(viMotionWordEnd, true)
+	case '0':
+		v.motion(viMotionStartOfLine, false)
+	case '^':
+		v.motion(viMotionFirstNonBlank, false)
+	case '$':
+		v.motion(viMotionEndOfLine, false)
+	case '%':
+		v.motion(viMotionMatchingBracket, true)
+	case 'f', 't', 'F', 'T':
+		v.pending = r
+	case ';', ',':
+		if v.lastFind == 0 {
+			v.resetCommand()
+			return
+		}
+		command := v.lastFind
+		if r == ',' {
+			command = reverseFindCommand(command)
+		}
+		v.motion(repeatFindCharMotion(command, v.lastFindChar), command == 'f' || command == 't')
+	case 'd', 'c', 'y':
+		if visual {
+			v.takeCount()
+			v.applyOperator(r, line.selection.start, line.selection.end)
+		} else if v.operator == r {
+			v.linewiseOperator(r)
+		} else if v.operator == 0 {
+			v.operator = r
+		} else {
+			v.resetCommand()
+		}
+	case 'x':
+		if visual {
+			v.takeCount()
+			v.applyOperator('d', line.selection.start, line.selection.end)
+		} else {
+			count := minInt(v.takeCount(), utf8.RuneCountInString(line.Document().CurrentLineAfterCursor()))
+			v.applyOperator('d', line.cursorPosition, line.cursorPosition+count)
+		}
+	case 'X':
+		v.operator = 'd'
+		v.motion(viMotionLeft, false)
+	case 'D':
+		v.operator = 'd'
+		v.motion(viMotionEndOfLine, false)
+	case 'C':
+		v.operator = 'c'
+		v.motion(viMotionEndOfLine, false)
+	case 's':
+		v.operator = 'c'
+		v.motion(viMotionRight, false)
+	case 'S':
+		v.linewiseOperator('c')
+	case 'r':
+		v.pending = r
+	case 'p', 'P':
+		v.paste(r == 'p')
+	case 'i':
+		v.resetCommand()
+		v.toInsertMode()
+	case 'a':
+		v.resetCommand()
+		if !line.Document().isCursorAtTheEndOfLine() {
+			line.CursorRight()
+		}
+		v.toInsertMode()
+	case 'I':
+		v.resetCommand()
+		line.CursorToStartOfLine(true)
+		v.toInsertMode()
+	case 'A':
+		v.resetCommand()
+		line.CursorToEndOfLine()
+		v.toInsertMode()
+	case 'o':
+		v.resetCommand()
+		line.InsertLineBelow(line.autoIndent)
+		v.toInsertMode()
+	case 'O':
+		v.resetCommand()
+		line.InsertLineAbove(line.autoIndent)
+		v.toInsertMode()
+	case 'j', 'k':
+		count := v.takeCount()
+		v.resetCommand()
+		for i := 0; i < count; i++ {
+			if r == 'j' {
+				line.AutoDown()
+			} else {
+				line.AutoUp()
+			}
+		}
+	case 'u':
+		v.resetCommand()
+		line.Undo()
+	case 'v':
+		v.resetCommand()
+		if visual {
+			v.toNormalMode()
+		} else {
+			v.toVisualMode()
+		}
+	default:
+		v.resetCommand()
+	}
+}
+
+// motion 执行移动命令，如果有操作符，则对光标到移动位置之间的文本执行操作
+// inclusive 表示移动位置处的字符是否也包括在操作范围内
+func (v *ViHandler) motion(motion _ViMotion, inclusive bool) {
+	line := v.line
+	count := v.takeCount()
+	text := line.text()
+	pos := line.cursorPosition
+	for i := 0; i < count; i++ {
+		pos = motion(NewDocument(text, pos))
+		if pos < 0 {
+			//    移动失败时取消整个命令，比如 ctz 找不到 z 时不会进入 insert 模式
+			v.resetCommand()
+			return
+		}
+	}
+	if v.operator == 0 {
+		line.SetCursorPosition(pos)
+		return
+	}
+	start, end := line.cursorPosition, pos
+	if start > end {
+		start, end = end, start
+	}
+	if inclusive && start != end {
+		end++
+	}
+	v.applyOperator(v.operator, start, end)
+}
+
+// linewiseOperator 执行 dd cc yy 这类整行的操作
+func (v *ViHandler) linewiseOperator(operator rune) {
+	line := v.line
+	count := v.takeCount()
+	document := line.Document()
+	row := document.CursorPositionRow()
+	lineStarts := document.lineStartIndexes()
+	lineLengths := document.lineLengths()
+	lastRow := minInt(row+count, len(lineStarts)) - 1
+	start := lineStarts[row]
+	end := lineStarts[lastRow] + lineLengths[lastRow]
+	text := string(line.buffer[start:end])
+	v.resetCommand()
+	switch operator {
+	case 'd':
+		//    连同换行符一起删除
+		if lastRow+1 < len(lineStarts) {
+			line.removeRunes(start, end+1-start)
+		} else if row > 0 {
+			line.removeRunes(start-1, end+1-start)
+		} else {
+			line.removeRunes(start, end-start)
+		}
+		line.SetCursorPosition(minInt(start, len(line.buffer)))
+		line.CursorToStartOfLine(true)
+	case 'c':
+		//    开启自动缩进时，跟 vi 一样保留第一行的缩进
+		indent := ""
+		if line.autoIndent {
+			indent = document.LeadingWhitespaceInCurrentLine()
+		}
+		line.removeRunes(start, end-start)
+		line.SetCursorPosition(start)
+		line.insertText([]rune(indent), false)
+		v.toInsertMode()
+	case 'y':
+		line.SetCursorPosition(start)
+	}
+	//    空行也放入 kill ring ，这样 p 可以粘贴出空行
+	line.killRing.add(text, false, false)
+	v.linewiseText = text + "\n"
+}
+
+// applyOperator 对 [start, end) 之间的文本执行操作
+func (v *ViHandler) applyOperator(operator rune, start int, end int) {
+	line := v.line
+	v.resetCommand()
+	switch operator {
+	case 'd', 'c':
+		line.KillRegion(start, end)
+	case 'y':
+		line.CopyRegion(start, end)
+	}
+	line.SetCursorPosition(start)
+	if operator == 'c' {
+		line.selection = _LineArea{-1, -1}
+		v.toInsertMode()
+	} else if line.EditMode().Is(linemode.ViVisual) {
+		v.toNormalMode()
+	}
+}
+
+// paste 粘贴最近删除或者复制的文本， after 为 true 表示粘贴在光标后面（ p ），否则是光标前面（ P ）
+func (v *ViHandler) paste(after bool) {
+	line := v.line
+	count := v.takeCount()
+	v.resetCommand()
+	if line.killRing.length() == 0 {
+		return
+	}
+	text := strings.Repeat(line.killRing.get(0), count)
+	if line.killRing.get(0)+"\n" == v.linewiseText {
+		text = strings.TrimSuffix(strings.Repeat(v.linewiseText, count), "\n")
+		if after {
+			line.CursorToEndOfLine()
+			line.insertText([]rune("\n"+text), false)
+			line.SetCursorPosition(line.cursorPosition + 1)
+		} else {
+			line.CursorToStartOfLine(false)
+			line.insertText([]rune(text+"\n"), false)
+		}
+		line.CursorToStartOfLine(true)
+		return
+	}
+	if after && !line.Document().isCursorAtTheEndOfLine() {
+		line.CursorRight()
+	}
+	line.insertText([]rune(text), true)
+	//    光标停在粘贴文本的最后一个字符上
+	line.CursorLeft()
+}
+
+// replaceCharacter 将光标处的字符替换为 r （ r 命令）
+func (v *ViHandler) replaceCharacter(r rune) {
+	line := v.line
+	count := v.takeCount()
+	v.resetCommand()
+	if utf8.RuneCountInString(line.Document().CurrentLineAfterCursor()) < count {
+		return
+	}
+	line.OverwriteText([]rune(strings.Repeat(string(r), count)), true)
+	line.CursorLeft()
+}
+
+func viMotionLeft(document *Document) int {
+	if document.CurrentLineBeforeCursor() == "" {
+		return document.CursorPosition()
+	}
+	return document.CursorPosition() - 1
+}
+
+// viMotionRight 可以移动到行尾，这样 dl 这类操作可以包括行的最后一个字符
+func viMotionRight(document *Document) int {
+	if document.isCursorAtTheEndOfLine() {
+		return document.CursorPosition()
+	}
+	return document.CursorPosition() + 1
+}
+
+func viMotionWordForward(document *Document) int {
+	offset := document.findNextWordBeginning()
+	if offset == 0 {
+		//    没有下一个单词，移动到文本末尾
+		return utf8.RuneCountInString(document.Text())
+	}
+	return document.CursorPosition() + offset
+}
+
+func viMotionWordBack(document *Document) int {
+	return document.CursorPosition() + document.findStartOfPreviousWord()
+}
+
+// viMotionWordEnd 移动到单词的最后一个字符上
+func viMotionWordEnd(document *Document) int {
+	offset := document.findNextWordEnding(false)
+	if offset > 1 {
+		return document.CursorPosition() + offset - 1
+	}
+	return document.CursorPosition()
+}
+
+func viMotionStartOfLine(document *Document) int {
+	return document.CursorPosition() - utf8.RuneCountInString(document.CurrentLineBeforeCursor())
+}
+
+func viMotionFirstNonBlank(document *Document) int {
+	return viMotionStartOfLine(document) + utf8.RuneCountInString(document.LeadingWhitespaceInCurrentLine())
+}
+
+func viMotionEndOfLine(document *Document) int {
+	return document.CursorPosition() + utf8.RuneCountInString(document.CurrentLineAfterCursor())
+}
+
+// viMotionMatchingBracket 移动到匹配的括号，找不到返回 -1
+// 跟 vi 一样，光标不在括号上时，先在当前行向右查找第一个括号
+func viMotionMatchingBracket(document *Document) int {
+	if pos := document.findMatchingBracket(); pos >= 0 {
+		return pos
+	}
+	offset := strings.IndexFunc(document.CurrentLineAfterCursor(), func(r rune) bool {
+		return strings.ContainsRune("()[]{}", r)
+	})
+	if offset < 0 {
+		return -1
+	}
+	pos := document.CursorPosition() + utf8.RuneCountInString(document.CurrentLineAfterCursor()[:offset])
+	return NewDocument(document.Text(), pos).findMatchingBracket()
+}
+
+// findCharMotion 在当前行查找字符 c ，找不到返回 -1
+// f 和 t 向右查找，分别移动到字符上和字符前； F 和 T 向左查找，分别移动到字符上和字符后
+func findCharMotion(command rune, c rune) _ViMotion {
+	return func(document *Document) int {
+		pos := document.CursorPosition()
+		switch command {
+		case 'f', 't':
+			after := []rune(document.CurrentLineAfterCursor())
+			for i := 1; i < len(after); i++ {
+				if after[i] == c {
+					if command == 't' {
+						return pos + i - 1
+					}
+					return pos + i
+				}
+			}
+		case 'F', 'T':
+			before := []rune(document.CurrentLineBeforeCursor())
+			for i := len(before) - 1; i >= 0; i-- {
+				if before[i] == c {
+					if command == 'T' {
+						return pos - len(before) + i + 1
+					}
+					return pos - len(before) + i
+				}
+			}
+		}
+		return -1
+	}
+}
+
+// repeatFindCharMotion ; 和 , 重复查找，跟 findCharMotion 一样，
+// 但是 t 和 T 会跳过光标旁边的字符 c ，否则光标停在 c 旁边时重复查找不会移动
+func repeatFindCharMotion(command rune, c rune) _ViMotion {
+	motion := findCharMotion(command, c)
+	if command != 't' && command != 'T' {
+		return motion
+	}
+	return func(document *Document) int {
+		pos := document.CursorPosition()
+		runes := []rune(document.Text())
+		if command == 't' && pos+1 < len(runes) && runes[pos+1] == c {
+			pos++
+		} else if command == 'T' && pos > 0 && runes[pos-1] == c {
+			pos--
+		}
+		return motion(NewDocument(document.Text(), pos))
+	}
+}
+
+// reverseFindCommand 返回反方向的查找命令，用于 , 命令
+func reverseFindCommand(command rune) rune {
+	switch command {
+	case 'f':
+		return 'F'
+	case 'F':
+		return 'f'
+	case 't':
+		return 'T'
+	default:
+		return 't'
+	}
+}
@@ -0,0 +1,175 @@
+package startprompt
+
+import (
+	"testing"
+
+	"github.com/yetsing/startprompt/enums/linemode"
+	"github.com/yetsing/startprompt/token"
+)
+
+func runViScript(t *testing.T, script string) *HeadlessResult {
+	t.Helper()
+	result, err := RunHeadless(&CommandLineOption{
+		Handler:  NewViHandler(),
+		SizeFunc: func() (int, int) { return 40, 10 },
+	}, script)
+	if err != nil {
+		t.Fatalf("script=%q err=%v", script, err)
+	}
+	return result
+}
+
+func TestViHandler(t *testing.T) {
+	tests := []struct {
+		script string
+		text   string
+	}{
+		//    insert 模式跟默认处理器一样
+		{"hello world", "hello world"},
+		{"hello world<esc>0dw", "world"},
+		{"one two three<esc>02dw", "three"},
+		{"one two three<esc>0d2w", "three"},
+		{"one two three<esc>0wcwxx", "one xx three"},
+		{"one two three<esc>0de", " two three"},
+		{"one two three<esc>b", "one two three"},
+		{"one two three<esc>bd$", "one two "},
+		//    快速按下 Esc 和字符被识别成 Meta 组合键
+		{"one two three<meta_b>d$", "one two "},
+		{"one two three<esc>0dfw", "o three"},
+		{"one two three<esc>0dtw", "wo three"},
+		{"one two three<esc>Fod0", "o three"},
+		{"f(a, b) + 1<esc>0f(d%", "f + 1"},
+		//    光标不在括号上时，向右查找第一个括号
+		{"f(a, b) + 1<esc>0d%", " + 1"},
+		{"f(a, b) + 1<esc>0%x", "f(a, b + 1"},
+		//    当前行右边没有括号时，移动失败，取消命令
+		{"f(a, b) + 1<esc>$c%x", "f(a, b) + "},
+		//    找不到字符时取消命令，不会进入 insert 模式
+		{"one two<esc>0ctzx", "ne two"},
+		//    重复 t T 时跳过光标旁边的字符
+		{"a,b,c,d<esc>0t,;x", "a,,c,d"},
+		{"a,b,c,d<esc>$T,;x", "a,b,,d"},
+		{"a,b,c,d<esc>0t,;;,x", "a,,c,d"},
+		{"abc<esc>0x", "bc"},
+		{"abc<esc>03x", ""},
+		{"abc<esc>X", "ac"},
+		{"abc<esc>0rz", "zbc"},
+		{"abc<esc>0Ax", "abcx"},
+		{"abc<esc>0Ix", "xabc"},
+		{"abc<esc>0ax", "axbc"},
+		{"one two<esc>0Cx", "x"},
+		{"one two<esc>0D", ""},
+		{"one two<esc>0yep", "oonene two"},
+		{"one two<esc>0ywP", "one one two"},
+		{"one two<esc>0dwu", "one two"},
+		{"one two<esc>0dwu<ctrl_r>", "two"},
+		{"one two<esc>0vlld", " two"},
+		{"one two<esc>0wvly$p", "one twotw"},
+		{"one two<esc>0wvbc1", "1wo"},
+		{"one<enter>", "one"},
+	}
+	for _, tt := range tests {
+		result := runViScript(t, tt.script)
+		if result.Text != tt.text {
+			t.Errorf("script=%q text want=%q got=%q", tt.script, tt.text, result.Text)
+		}
+	}
+}
+
+func TestViHandler_Linewise(t *testing.T) {
+	line := newTestLine()
+	handler := NewViHandler()
+	handler.initLine(line)
+	line.InsertText([]rune("one\ntwo\nthree"), true)
+	handler.toNormalMode()
+	line.SetCursorPosition(5)
+
+	for _, r := range "yyp" {
+		handler.feed(r)
+	}
+	testStringEqual(t, "one\ntwo\ntwo\nthree", line.text())
+	for _, r := range "2dd" {
+		handler.feed(r)
+	}
+	testStringEqual(t, "one\ntwo", line.text())
+	for _, r := range "kP" {
+		handler.feed(r)
+	}
+	testStringEqual(t, "two\nthree\none\ntwo", line.text())
+}
+
+func TestViHandler_LinewiseEmptyLine(t *testing.T) {
+	line := newTestLine()
+	handler := NewViHandler()
+	handler.initLine(line)
+	line.InsertText([]rune("one\n\ntwo"), true)
+	handler.toNormalMode()
+	line.SetCursorPosition(4)
+
+	//    复制空行也按整行粘贴
+	for _, r := range "yyjp" {
+		handler.feed(r)
+	}
+	testStringEqual(t, "one\n\ntwo\n", line.text())
+	for _, r := range "kkP" {
+		handler.feed(r)
+	}
+	testStringEqual(t, "one\n\n\ntwo\n", line.text())
+}
+
+func TestViHandler_ChangeLineAutoIndent(t *testing.T) {
+	tests := []struct {
+		script     string
+		autoIndent bool
+		text       string
+	}{
+		//    开启自动缩进时保留缩进
+		{"cc", true, "if a:\n    "},
+		{"S", true, "if a:\n    "},
+		{"cc", false, "if a:\n"},
+	}
+	for _, tt := range tests {
+		line := newLine(newBaseCode, NewMemHistory(), tt.autoIndent)
+		handler := NewViHandler()
+		handler.initLine(line)
+		line.InsertText([]rune("if a:\n    b = 1"), true)
+		handler.toNormalMode()
+		for _, r := range tt.script {
+			handler.feed(r)
+		}
+		testStringEqual(t, tt.text, line.text())
+		testBoolEqual(t, true, line.EditMode().Is(linemode.ViInsert))
+	}
+}
+
+func TestViHandler_ModePrompt(t *testing.T) {
+	result := runViScript(t, "abc")
+	testStringEqual(t, "[I] > abc", result.Screen())
+	result = runViScript(t, "abc<esc>")
+	testStringEqual(t, "[N] > abc", result.Screen())
+	result = runViScript(t, "abc<esc>v")
+	testStringEqual(t, "[V] > abc", result.Screen())
+	//    后续行跟第一行的输入对齐
+	result = runViScript(t, "\x1b[200~one\rtwo\x1b[201~")
+	testStringEqual(t, "[I] > one\n    . two", result.Screen())
+
+	//    自定义的提示符同样显示模式标识
+	result, err := RunHeadless(&CommandLineOption{
+		Handler: NewViHandler(),
+		PromptFactory: func(code Code) Prompt {
+			return &_TestViPrompt{}
+		},
+	}, "abc<esc>")
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	testStringEqual(t, "[N] $ abc", result.Screen())
+}
+
+type _TestViPrompt struct {
+	BasePrompt
+}
+
+func (p *_TestViPrompt) GetPrompt() []token.Token {
+	return []token.Token{token.NewToken(token.Prompt, "$ ")}
+}
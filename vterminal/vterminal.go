@@ -0,0 +1,392 @@
+package vterminal
+
+/*
+虚拟终端，解析写入的 VT100 转义序列，保存屏幕上每个位置的字符和样式
+只支持 startprompt 输出用到的序列，主要用于测试
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Style 字符样式，对应 SGR 参数
+type Style struct {
+	Fg        string
+	Bg        string
+	Bold      bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+}
+
+// IsDefault 是否是默认样式
+func (s Style) IsDefault() bool {
+	return s == Style{}
+}
+
+// String 返回样式的文本表示，比如 "fg=201,bg=236,bold" ，默认样式返回空字符串
+func (s Style) String() string {
+	var attrs []string
+	if s.Fg != "" {
+		attrs = append(attrs, "fg="+s.Fg)
+	}
+	if s.Bg != "" {
+		attrs = append(attrs, "bg="+s.Bg)
+	}
+	if s.Bold {
+		attrs = append(attrs, "bold")
+	}
+	if s.Italic {
+		attrs = append(attrs, "italic")
+	}
+	if s.Underline {
+		attrs = append(attrs, "underline")
+	}
+	if s.Reverse {
+		attrs = append(attrs, "reverse")
+	}
+	return strings.Join(attrs, ",")
+}
+
+// Cell 屏幕上的一个位置
+type Cell struct {
+	Char  string
+	Style Style
+	//    宽字符（比如中文）会占用两个位置，第二个位置的 Char 为空字符串
+	continuation bool
+}
+
+// Terminal 虚拟终端，实现了 io.Writer （ goroutine 安全）
+type Terminal struct {
+	mutex  sync.Mutex
+	width  int
+	height int
+	cells  [][]Cell
+	//    光标位置
+	x int
+	y int
+	//    写满一行最后一列后，光标不会马上换行，而是等到下一个字符写入时才换行（跟 xterm 一样）
+	wrapPending bool
+	style       Style
+	//    还没处理完的转义序列
+	pending []rune
+}
+
+// New 新建指定宽度和高度的虚拟终端
+func New(width int, height int) *Terminal {
+	t := &Terminal{width: width, height: height}
+	t.cells = make([][]Cell, height)
+	for y := range t.cells {
+		t.cells[y] = t.newLine()
+	}
+	return t
+}
+
+func (t *Terminal) newLine() []Cell {
+	line := make([]Cell, t.width)
+	for x := range line {
+		line[x] = Cell{Char: " "}
+	}
+	return line
+}
+
+// Size 返回终端宽度和高度
+func (t *Terminal) Size() (int, int) {
+	return t.width, t.height
+}
+
+// Cursor 返回光标位置
+func (t *Terminal) Cursor() (int, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.x, t.y
+}
+
+// Cell 返回指定位置的字符和样式
+func (t *Terminal) Cell(x int, y int) Cell {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.cells[y][x]
+}
+
+// Write 写入数据，解析其中的转义序列
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, r := range string(p) {
+		t.feed(r)
+	}
+	return len(p), nil
+}
+
+func (t *Terminal) feed(r rune) {
+	if len(t.pending) > 0 {
+		t.pending = append(t.pending, r)
+		t.parseEscape()
+		return
+	}
+	switch r {
+	case '\x1b':
+		t.pending = append(t.pending, r)
+	case '\r':
+		t.x = 0
+		t.wrapPending = false
+	case '\n':
+		t.lineFeed()
+	case '\b':
+		t.moveTo(t.x-1, t.y)
+	default:
+		if r < ' ' || r == '\x7f' {
+			return
+		}
+		t.put(r)
+	}
+}
+
+// parseEscape 解析转义序列，序列不完整时等待后续字符
+func (t *Terminal) parseEscape() {
+	if len(t.pending) < 2 {
+		return
+	}
+	if t.pending[1] == ']' {
+		//    OSC 序列（比如 OSC 52 写入剪贴板）以 BEL 或者 ESC \ 结束，不影响屏幕，直接丢弃
+		n := len(t.pending)
+		if t.pending[n-1] == '\a' || (n > 3 && t.pending[n-2] == '\x1b' && t.pending[n-1] == '\\') {
+			t.pending = nil
+		}
+		return
+	}
+	if t.pending[1] != '[' {
+		//    不支持的转义序列，直接丢弃
+		t.pending = nil
+		return
+	}
+	last := t.pending[len(t.pending)-1]
+	//    CSI 序列以 0x40-0x7e 之间的字符结束
+	if len(t.pending) == 2 || last < 0x40 || last > 0x7e {
+		return
+	}
+	params := string(t.pending[2 : len(t.pending)-1])
+	t.pending = nil
+	t.csi(params, last)
+}
+
+func (t *Terminal) csi(params string, final rune) {
+	if strings.HasPrefix(params, "?") {
+		//    私有模式，比如显示隐藏光标，忽略
+		return
+	}
+	//    跟真实终端一样，移动光标的参数为 0 时当作 1 处理
+	amount := maxInt(paramAt(params, 0, 1), 1)
+	switch final {
+	case 'A':
+		t.moveTo(t.x, t.y-amount)
+	case 'B':
+		t.moveTo(t.x, t.y+amount)
+	case 'C':
+		t.moveTo(t.x+amount, t.y)
+	case 'D':
+		t.moveTo(t.x-amount, t.y)
+	case 'H':
+		t.moveTo(paramAt(params, 1, 1)-1, paramAt(params, 0, 1)-1)
+	case 'J':
+		switch paramAt(params, 0, 0) {
+		case 0:
+			t.eraseLine(t.y, t.x, t.width)
+			for y := t.y + 1; y < t.height; y++ {
+				t.cells[y] = t.newLine()
+			}
+		case 2:
+			for y := range t.cells {
+				t.cells[y] = t.newLine()
+			}
+		}
+	case 'K':
+		t.eraseLine(t.y, t.x, t.width)
+	case 'm':
+		t.sgr(params)
+	}
+}
+
+func (t *Terminal) sgr(params string) {
+	parts := strings.Split(params, ";")
+	for i := 0; i < len(parts); i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			n = 0
+		}
+		switch {
+		case n == 0:
+			t.style = Style{}
+		case n == 1:
+			t.style.Bold = true
+		case n == 3:
+			t.style.Italic = true
+		case n == 4:
+			t.style.Underline = true
+		case n == 7:
+			t.style.Reverse = true
+		case n == 22:
+			t.style.Bold = false
+		case n == 23:
+			t.style.Italic = false
+		case n == 24:
+			t.style.Underline = false
+		case n == 27:
+			t.style.Reverse = false
+		case (n == 38 || n == 48) && i+2 < len(parts) && parts[i+1] == "5":
+			if n == 38 {
+				t.style.Fg = parts[i+2]
+			} else {
+				t.style.Bg = parts[i+2]
+			}
+			i += 2
+		case n == 39:
+			t.style.Fg = ""
+		case n == 49:
+			t.style.Bg = ""
+		case 30 <= n && n <= 37, 90 <= n && n <= 97:
+			t.style.Fg = strconv.Itoa(n)
+		case 40 <= n && n <= 47, 100 <= n && n <= 107:
+			t.style.Bg = strconv.Itoa(n - 10)
+		}
+	}
+}
+
+func (t *Terminal) put(r rune) {
+	w := runewidth.RuneWidth(r)
+	if w <= 0 {
+		return
+	}
+	if t.wrapPending || t.x+w > t.width {
+		t.x = 0
+		t.lineFeed()
+	}
+	t.cells[t.y][t.x] = Cell{Char: string(r), Style: t.style}
+	for i := 1; i < w; i++ {
+		t.cells[t.y][t.x+i] = Cell{Style: t.style, continuation: true}
+	}
+	if t.x+w >= t.width {
+		t.x = t.width - 1
+		t.wrapPending = true
+	} else {
+		t.x += w
+	}
+}
+
+func (t *Terminal) lineFeed() {
+	t.wrapPending = false
+	if t.y == t.height-1 {
+		//    到达底部，屏幕向上滚动一行
+		t.cells = append(t.cells[1:], t.newLine())
+	} else {
+		t.y++
+	}
+}
+
+func (t *Terminal) moveTo(x int, y int) {
+	t.x = clamp(x, 0, t.width-1)
+	t.y = clamp(y, 0, t.height-1)
+	t.wrapPending = false
+}
+
+func (t *Terminal) eraseLine(y int, from int, to int) {
+	for x := from; x < to; x++ {
+		t.cells[y][x] = Cell{Char: " "}
+	}
+}
+
+// Lines 返回屏幕每一行的文本（去掉行尾空白）
+func (t *Terminal) Lines() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	lines := make([]string, t.height)
+	for y, line := range t.cells {
+		var sb strings.Builder
+		for _, cell := range line {
+			sb.WriteString(cell.Char)
+		}
+		lines[y] = strings.TrimRight(sb.String(), " ")
+	}
+	return lines
+}
+
+// PlainText 返回屏幕的文本，去掉底部的空行
+func (t *Terminal) PlainText() string {
+	return joinLines(t.Lines())
+}
+
+// StyledText 返回带样式标记的屏幕文本，去掉底部的空行
+// 样式变化的地方会插入 {样式} 标记，比如 "> {fg=201}if{} a" ，回到默认样式时插入 {}
+func (t *Terminal) StyledText() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	lines := make([]string, t.height)
+	for y, line := range t.cells {
+		//    行尾的默认样式空白不需要输出
+		end := len(line)
+		for end > 0 && line[end-1].Char == " " && line[end-1].Style.IsDefault() {
+			end--
+		}
+		var sb strings.Builder
+		style := Style{}
+		for _, cell := range line[:end] {
+			if cell.continuation {
+				continue
+			}
+			if cell.Style != style {
+				sb.WriteString(fmt.Sprintf("{%s}", cell.Style))
+				style = cell.Style
+			}
+			sb.WriteString(cell.Char)
+		}
+		if !style.IsDefault() {
+			sb.WriteString("{}")
+		}
+		lines[y] = sb.String()
+	}
+	return joinLines(lines)
+}
+
+func joinLines(lines []string) string {
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	return strings.Join(lines[:end], "\n")
+}
+
+// paramAt 返回 CSI 序列第 index 个数字参数，没有的话返回 defaultValue
+func paramAt(params string, index int, defaultValue int) int {
+	parts := strings.Split(params, ";")
+	if index >= len(parts) || parts[index] == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(parts[index])
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clamp(n int, low int, high int) int {
+	if n < low {
+		return low
+	}
+	if n > high {
+		return high
+	}
+	return n
+}
@@ -0,0 +1,50 @@
+package vterminal
+
+import "testing"
+
+func TestTerminal_Write(t *testing.T) {
+	tests := []struct {
+		input  string
+		plain  string
+		styled string
+	}{
+		{"abc", "abc", "abc"},
+		{"abc\r\ndef", "abc\ndef", "abc\ndef"},
+		{"abcdef\x1b[3D\x1b[K", "abc", "abc"},
+		{"abc\x1b[1D\x1b[1Ax\x1b[1By", "abx\n   y", "abx\n   y"},
+		{"a\x1b[38;5;201mb\x1b[39mc", "abc", "a{fg=201}b{}c"},
+		{"\x1b[7;01mab\x1b[00m", "ab", "{bold,reverse}ab{}"},
+		{"\x1b[?25lab\x1b[?25h", "ab", "ab"},
+		{"中a", "中a", "中a"},
+	}
+	for _, tt := range tests {
+		terminal := New(10, 3)
+		_, _ = terminal.Write([]byte(tt.input))
+		if got := terminal.PlainText(); got != tt.plain {
+			t.Errorf("input=%q plain want=%q got=%q", tt.input, tt.plain, got)
+		}
+		if got := terminal.StyledText(); got != tt.styled {
+			t.Errorf("input=%q styled want=%q got=%q", tt.input, tt.styled, got)
+		}
+	}
+}
+
+func TestTerminal_WriteOSC(t *testing.T) {
+	for _, osc := range []string{"\x1b]52;c;aGVsbG8=\a", "\x1b]52;c;aGVsbG8=\x1b\\"} {
+		terminal := New(10, 3)
+		_, _ = terminal.Write([]byte("ab"))
+		//    OSC 序列分开写入，模拟分批到来的输出
+		_, _ = terminal.Write([]byte(osc[:5]))
+		_, _ = terminal.Write([]byte(osc[5:]))
+		if got := terminal.PlainText(); got != "ab" {
+			t.Errorf("osc=%q plain want=%q got=%q", osc, "ab", got)
+		}
+		if x, y := terminal.Cursor(); x != 2 || y != 0 {
+			t.Errorf("osc=%q cursor want=(2, 0) got=(%d, %d)", osc, x, y)
+		}
+		_, _ = terminal.Write([]byte("c"))
+		if got := terminal.PlainText(); got != "abc" {
+			t.Errorf("osc=%q plain want=%q got=%q", osc, "abc", got)
+		}
+	}
+}